@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newCacheCmd() *cobra.Command {
+	cacheCmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the local chart cache used for offline installs",
+	}
+
+	var all bool
+	pullCmd := &cobra.Command{
+		Use:   "pull [tool]...",
+		Short: "Download the Helm charts of the enabled (or the given) tools into the cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			if offline {
+				return fmt.Errorf("cannot pull charts with --offline")
+			}
+			// The configuration pins chart versions and declares custom
+			// tools; without one, the named built-in tools are pulled in
+			// their latest versions.
+			cfg, err := loadConfig(log)
+			if errors.Is(err, config.ErrNotFound) && (len(args) > 0 || all) {
+				cfg, err = config.DefaultConfig(), nil
+			}
+			if err != nil {
+				return err
+			}
+			charts, err := tools.ChartTools(cfg)
+			if err != nil {
+				return err
+			}
+			selected := args
+			for _, tool := range selected {
+				if !contains(charts, tool) {
+					return fmt.Errorf("%q is not a chart-based tool (available: %s)", tool, strings.Join(charts, ", "))
+				}
+			}
+			if all {
+				selected = charts
+			}
+			if len(selected) == 0 {
+				if selected, err = tools.EnabledTools(cfg); err != nil {
+					return err
+				}
+			}
+			return tools.PullCharts(cmd.Context(), log, cfg, selected)
+		},
+	}
+	pullCmd.Flags().BoolVar(&all, "all", false, "Cache the charts of every chart-based tool, including custom ones")
+
+	cacheCmd.AddCommand(pullCmd)
+	return cacheCmd
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+)
+
+// resolveConfigFiles returns the configuration files given with --config.
+// Without the flag it uses $KINDCTL_CONFIG, or searches the current directory
+// and its parents up to the repository root. When nothing is found and
+// allowMissing is set, kindctl.yaml in the current directory is returned.
+func resolveConfigFiles(log *logger.Logger, allowMissing bool) ([]string, error) {
+	if len(configFiles) > 0 {
+		return configFiles, nil
+	}
+	if path := os.Getenv(config.EnvConfig); path != "" {
+		log.Debugf("Using configuration file %s from $%s", path, config.EnvConfig)
+		return []string{path}, nil
+	}
+	path, err := config.Discover(".")
+	if errors.Is(err, config.ErrNotFound) && allowMissing {
+		return []string{config.FileName}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Using configuration file %s", path)
+	return []string{path}, nil
+}
+
+// configOptions collects the configuration files and profiles selected on the
+// command line or through the environment.
+func configOptions(log *logger.Logger, allowMissing bool) (config.Options, error) {
+	files, err := resolveConfigFiles(log, allowMissing)
+	if err != nil {
+		return config.Options{}, err
+	}
+	selected := profiles
+	if len(selected) == 0 {
+		for _, name := range strings.Split(os.Getenv(config.EnvProfile), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				selected = append(selected, name)
+			}
+		}
+	}
+	return config.Options{Files: files, Profiles: selected}, nil
+}
+
+// loadLayered merges the configuration files given on the command line with
+// the local override file and the selected profiles.
+func loadLayered(log *logger.Logger) (*config.Layered, error) {
+	opts, err := configOptions(log, false)
+	if err != nil {
+		return nil, err
+	}
+	layered, err := config.Load(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return layered, nil
+}
+
+// loadConfig returns the effective configuration.
+func loadConfig(log *logger.Logger) (*config.Config, error) {
+	layered, err := loadLayered(log)
+	if err != nil {
+		return nil, err
+	}
+	return layered.Config, nil
+}
+
+func newConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the kindctl configuration",
+	}
+
+	var merged bool
+	viewCmd := &cobra.Command{
+		Use:   "view",
+		Short: "Print the configuration file, or the effective configuration with --merged",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			if !merged {
+				files, err := resolveConfigFiles(log, false)
+				if err != nil {
+					return err
+				}
+				data, err := os.ReadFile(files[0])
+				if err != nil {
+					return fmt.Errorf("failed to read config: %w", err)
+				}
+				_, err = cmd.OutOrStdout().Write(data)
+				return err
+			}
+			layered, err := loadLayered(log)
+			if err != nil {
+				return err
+			}
+			data, err := layered.View()
+			if err != nil {
+				return err
+			}
+			_, err = cmd.OutOrStdout().Write(data)
+			return err
+		},
+	}
+	viewCmd.Flags().BoolVar(&merged, "merged", false, "Print the merged configuration and the file each value comes from")
+
+	getCmd := &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print an effective setting, e.g. postgres.version",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			layered, err := loadLayered(logger.NewLogger(logLevel))
+			if err != nil {
+				return err
+			}
+			value, err := layered.Value(args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), value)
+			return nil
+		},
+	}
+
+	var opts editOptions
+	setCmd := &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change a setting in place, keeping comments and formatting",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return editConfig(cmd.Context(), logger.NewLogger(logLevel), opts, map[string]string{args[0]: args[1]})
+		},
+	}
+	opts.register(setCmd)
+
+	configCmd.AddCommand(viewCmd, getCmd, setCmd)
+	return configCmd
+}
+
+// newToggleCmd returns the enable or disable command.
+func newToggleCmd(use string, enabled bool) *cobra.Command {
+	var opts editOptions
+	cmd := &cobra.Command{
+		Use:       use + " <tool>...",
+		Short:     strings.ToUpper(use[:1]) + use[1:] + " tools in the configuration file",
+		Args:      cobra.MinimumNArgs(1),
+		ValidArgs: config.ToolNames(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			changes := map[string]string{}
+			for _, tool := range args {
+				if !contains(config.ToolNames(), tool) {
+					return fmt.Errorf("unknown tool %q (available: %s)", tool, strings.Join(config.ToolNames(), ", "))
+				}
+				changes[tool+".enabled"] = fmt.Sprint(enabled)
+			}
+			return editConfig(cmd.Context(), logger.NewLogger(logLevel), opts, changes)
+		},
+	}
+	opts.register(cmd)
+	return cmd
+}
+
+// editOptions are the flags shared by the commands that modify the
+// configuration file.
+type editOptions struct {
+	local  bool
+	update bool
+}
+
+func (o *editOptions) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.local, "local", false, "Write to "+config.LocalFileName+" instead of the shared file")
+	cmd.Flags().BoolVar(&o.update, "update", false, "Update the cluster after saving")
+}
+
+// editConfig applies the changes to the configuration file selected by opts
+// and optionally updates the cluster.
+func editConfig(ctx context.Context, log *logger.Logger, opts editOptions, changes map[string]string) error {
+	files, err := resolveConfigFiles(log, false)
+	if err != nil {
+		return err
+	}
+	path := files[0]
+	if opts.local {
+		path = config.LocalPath(path)
+	}
+	doc, err := config.OpenDocument(path)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := doc.Set(key, changes[key]); err != nil {
+			return err
+		}
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+	for _, key := range keys {
+		log.Infof("Set %s to %s in %s", key, changes[key], path)
+	}
+	if opts.update {
+		return runUpdate(ctx, log)
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
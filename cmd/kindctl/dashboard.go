@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newDashboardCmd() *cobra.Command {
+	dashboardCmd := &cobra.Command{
+		Use:   "dashboard",
+		Short: "Work with the Kubernetes Dashboard",
+	}
+
+	var duration time.Duration
+	tokenCmd := &cobra.Command{
+		Use:   "token",
+		Short: "Print a login token for the dashboard admin user",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			token, err := tools.DashboardToken(cmd.Context(), cfg, duration)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), token)
+			return nil
+		},
+	}
+	tokenCmd.Flags().DurationVar(&duration, "duration", 24*time.Hour, "Lifetime of the token")
+
+	dashboardCmd.AddCommand(tokenCmd)
+	return dashboardCmd
+}
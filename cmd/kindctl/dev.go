@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"kindctl/internal/apps"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newDevCmd() *cobra.Command {
+	var (
+		noWatch   bool
+		withTools bool
+	)
+	cmd := &cobra.Command{
+		Use:   "dev [app]...",
+		Short: "Build, load and deploy the apps, redeploying them on file changes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			selected, err := apps.Find(cfg, args)
+			if err != nil {
+				return err
+			}
+			if withTools {
+				if err := tools.UpdateCluster(cmd.Context(), log, cfg, tools.UpdateOptions{Offline: offline, Parallel: parallel, StepTimeout: stepTimeout}); err != nil {
+					return err
+				}
+			}
+			return apps.Dev(cmd.Context(), log, cfg, selected, !noWatch)
+		},
+	}
+	cmd.Flags().BoolVar(&noWatch, "no-watch", false, "Build and deploy once, then exit")
+	cmd.Flags().BoolVar(&withTools, "with-tools", false, "Install or update the tools before deploying the apps")
+	return cmd
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/config"
+	"kindctl/internal/images"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newImagesCmd() *cobra.Command {
+	imagesCmd := &cobra.Command{
+		Use:   "images",
+		Short: "List, pull and load the container images used by the tools",
+	}
+
+	// resolve returns the images of the given tools, or of the enabled tools.
+	resolve := func(ctx context.Context, log *logger.Logger, args []string) (*config.Config, []tools.ToolImages, error) {
+		cfg, err := loadConfig(log)
+		if err != nil {
+			return nil, nil, err
+		}
+		resolved, err := tools.Images(ctx, log, cfg, tools.UpdateOptions{Offline: offline}, args)
+		return cfg, resolved, err
+	}
+	flatten := func(resolved []tools.ToolImages) []string {
+		var all []string
+		for _, ti := range resolved {
+			all = append(all, ti.Images...)
+		}
+		return all
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list [tool]...",
+		Short: "List the images of the enabled (or the given) tools",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			_, resolved, err := resolve(cmd.Context(), log, args)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TOOL\tIMAGE\tPRESENT\tLOCKED DIGEST")
+			for _, ti := range resolved {
+				for _, image := range ti.Images {
+					digest := ti.Digests[image]
+					if digest == "" {
+						digest = "-"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%t\t%s\n", ti.Tool, image, images.Present(cmd.Context(), image), digest)
+				}
+			}
+			return w.Flush()
+		},
+	}
+
+	pullCmd := &cobra.Command{
+		Use:   "pull [tool]...",
+		Short: "Pull the images of the enabled (or the given) tools into the local Docker cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			_, resolved, err := resolve(cmd.Context(), log, args)
+			if err != nil {
+				return err
+			}
+			return images.Pull(cmd.Context(), log, flatten(resolved), offline)
+		},
+	}
+
+	loadCmd := &cobra.Command{
+		Use:   "load [tool]...",
+		Short: "Pull the images of the enabled (or the given) tools and load them into the cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			cfg, resolved, err := resolve(cmd.Context(), log, args)
+			if err != nil {
+				return err
+			}
+			all := flatten(resolved)
+			if err := images.Pull(cmd.Context(), log, all, offline); err != nil {
+				return err
+			}
+			return images.Load(cmd.Context(), log, cfg.Cluster.Name, all)
+		},
+	}
+
+	imagesCmd.AddCommand(listCmd, pullCmd, loadCmd)
+	return imagesCmd
+}
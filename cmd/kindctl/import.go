@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/compose"
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+)
+
+func newImportCmd() *cobra.Command {
+	importCmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import tools from other tool definitions",
+	}
+
+	var manifestsDir string
+	var opts editOptions
+	composeCmd := &cobra.Command{
+		Use:   "compose <file>",
+		Short: "Add the services of a docker-compose file to the configuration",
+		Long: `Maps the services of a docker-compose file onto the configuration.
+Known images (postgres, redis, rabbitmq, mailpit, adminer, pgadmin) enable the
+built-in tool with the credentials from their environment, services with a
+build section become apps and any other image becomes a custom tool whose
+manifests are written to the manifests directory. Variables such as ${VAR}
+and ${VAR:-default} are taken from the environment, then from the .env file
+next to the compose file.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			return importCompose(cmd.Context(), log, args[0], manifestsDir, opts)
+		},
+	}
+	composeCmd.Flags().StringVar(&manifestsDir, "manifests-dir", "manifests", "Directory for generated manifests, relative to the configuration file")
+	opts.register(composeCmd)
+
+	importCmd.AddCommand(composeCmd)
+	return importCmd
+}
+
+func importCompose(ctx context.Context, log *logger.Logger, file, manifestsDir string, opts editOptions) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	lookup, err := compose.Env(filepath.Dir(file))
+	if err != nil {
+		return err
+	}
+	f, err := compose.Parse(data, lookup)
+	if err != nil {
+		return err
+	}
+	result := compose.Convert(f, manifestsDir)
+
+	files, err := resolveConfigFiles(log, true)
+	if err != nil {
+		return err
+	}
+	path := files[0]
+	if opts.local {
+		path = config.LocalPath(path)
+	}
+	doc, err := config.OpenDocument(path)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(result.Settings))
+	for key := range result.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := doc.Set(key, result.Settings[key]); err != nil {
+			return err
+		}
+	}
+	for _, ct := range result.Custom {
+		if err := doc.Append("custom", ct); err != nil {
+			return err
+		}
+	}
+	for _, app := range result.Apps {
+		if err := doc.Append("apps", app); err != nil {
+			return err
+		}
+	}
+
+	dir := filepath.Join(filepath.Dir(path), manifestsDir)
+	if filepath.IsAbs(manifestsDir) {
+		dir = manifestsDir
+	}
+	names := make([]string, 0, len(result.Manifests))
+	for name := range result.Manifests {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 0 {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		target := filepath.Join(dir, name)
+		if err := os.WriteFile(target, result.Manifests[name], 0644); err != nil {
+			return err
+		}
+		log.Infof("Wrote %s", target)
+	}
+	if err := doc.Save(); err != nil {
+		return err
+	}
+
+	for _, warning := range result.Warnings {
+		log.Warnf("%s", warning)
+	}
+	for _, key := range keys {
+		log.Infof("Set %s to %s in %s", key, result.Settings[key], path)
+	}
+	for _, ct := range result.Custom {
+		log.Infof("Added custom tool %s to %s", ct.Name, path)
+	}
+	for _, app := range result.Apps {
+		log.Infof("Added app %s to %s", app.Name, path)
+	}
+	if opts.update {
+		return runUpdate(ctx, log)
+	}
+	return nil
+}
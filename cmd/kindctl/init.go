@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/cluster"
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+)
+
+func newInitCmd() *cobra.Command {
+	var (
+		with      []string
+		template  string
+		noCluster bool
+	)
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Initialize a new Kind cluster and create a default config file",
+		Long: `Initialize a new Kind cluster and create a default config file.
+
+When no configuration file exists, kindctl writes a commented one containing
+every tool section. Pick the enabled tools with --with and --template, or
+interactively when run in a terminal without either flag. Both flags are
+rejected for an existing file; use kindctl enable to change it.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			opts, err := configOptions(log, true)
+			if err != nil {
+				return err
+			}
+			initOpts := cluster.InitOptions{Config: opts, NoCluster: noCluster, Offline: offline, StepTimeout: stepTimeout}
+			if _, err := os.Stat(opts.Files[0]); os.IsNotExist(err) {
+				initOpts.Tools, err = selectTools(cmd, with, template)
+				if err != nil {
+					return err
+				}
+			} else if cmd.Flags().Changed("with") || cmd.Flags().Changed("template") {
+				return fmt.Errorf("%s already exists and --with and --template only apply to a new configuration file; enable tools with kindctl enable <tool>", opts.Files[0])
+			}
+			return cluster.Initialize(cmd.Context(), log, initOpts)
+		},
+	}
+	cmd.Flags().StringSliceVar(&with, "with", nil, "Tools to enable in the new config file, e.g. postgres,redis,mailpit")
+	cmd.Flags().StringVar(&template, "template", "", "Start from a predefined set of tools ("+strings.Join(config.TemplateNames(), ", ")+")")
+	cmd.Flags().BoolVar(&noCluster, "no-cluster", false, "Only write the config file, do not create the cluster")
+	return cmd
+}
+
+// selectTools returns the tools to enable in a new configuration file.
+func selectTools(cmd *cobra.Command, with []string, template string) ([]string, error) {
+	var tools []string
+	if template != "" {
+		preset, ok := config.Templates[template]
+		if !ok {
+			return nil, fmt.Errorf("unknown template %q (available: %s)", template, strings.Join(config.TemplateNames(), ", "))
+		}
+		tools = append(tools, preset...)
+	}
+	for _, tool := range with {
+		if !contains(tools, tool) {
+			tools = append(tools, tool)
+		}
+	}
+	if tools != nil || !isTerminal(os.Stdin) {
+		if tools == nil {
+			tools = config.Templates["minimal"]
+		}
+		return tools, nil
+	}
+	return pickTools(cmd.InOrStdin(), cmd.OutOrStdout())
+}
+
+// pickTools asks which tools to enable.
+func pickTools(in io.Reader, out io.Writer) ([]string, error) {
+	names := config.ToolNames()
+	defaults := config.Templates["minimal"]
+	fmt.Fprintln(out, "Available tools:")
+	for i, name := range names {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, name)
+	}
+	fmt.Fprintf(out, "Templates: %s\n", strings.Join(config.TemplateNames(), ", "))
+
+	reader := bufio.NewReader(in)
+	for {
+		fmt.Fprintf(out, "Tools to enable (names, numbers or a template) [%s]: ", strings.Join(defaults, ","))
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			return defaults, nil
+		}
+		tools, perr := parseSelection(line, names)
+		if perr == nil {
+			return tools, nil
+		}
+		fmt.Fprintln(out, perr)
+		if err == io.EOF {
+			return nil, perr
+		}
+	}
+}
+
+// parseSelection resolves a comma or space separated list of tool names,
+// numbers and template names.
+func parseSelection(line string, names []string) ([]string, error) {
+	var tools []string
+	add := func(tool string) {
+		if !contains(tools, tool) {
+			tools = append(tools, tool)
+		}
+	}
+	for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' }) {
+		if n, err := strconv.Atoi(field); err == nil {
+			if n < 1 || n > len(names) {
+				return nil, fmt.Errorf("no tool number %d", n)
+			}
+			add(names[n-1])
+			continue
+		}
+		if preset, ok := config.Templates[field]; ok {
+			for _, tool := range preset {
+				add(tool)
+			}
+			continue
+		}
+		if !contains(names, field) {
+			return nil, fmt.Errorf("unknown tool %q", field)
+		}
+		add(field)
+	}
+	return tools, nil
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
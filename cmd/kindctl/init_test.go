@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kindctl/internal/config"
+)
+
+func TestInitExistingConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.FileName)
+	t.Setenv(config.EnvConfig, path)
+	saved := logLevel
+	logLevel = "error"
+	defer func() { logLevel = saved }()
+
+	cmd := newInitCmd()
+	cmd.SetArgs([]string{"--no-cluster", "--with", "redis"})
+	assert.NoError(t, cmd.Execute())
+	cfg, err := config.LoadConfig(path)
+	assert.NoError(t, err)
+	assert.True(t, cfg.Redis.Enabled)
+	before, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	for _, args := range [][]string{{"--with", "postgres"}, {"--template", "full"}} {
+		cmd = newInitCmd()
+		cmd.SetArgs(append([]string{"--no-cluster"}, args...))
+		cmd.SilenceUsage, cmd.SilenceErrors = true, true
+		assert.ErrorContains(t, cmd.Execute(), "already exists", args)
+	}
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(before), string(after))
+
+	cmd = newInitCmd()
+	cmd.SetArgs([]string{"--no-cluster"})
+	assert.NoError(t, cmd.Execute())
+}
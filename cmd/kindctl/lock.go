@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newLockCmd() *cobra.Command {
+	lockCmd := &cobra.Command{
+		Use:   "lock",
+		Short: "Manage kindctl.lock, which pins chart versions and image digests",
+	}
+
+	updateCmd := &cobra.Command{
+		Use:   "update [tool]...",
+		Short: "Re-resolve the chart versions and image digests of the enabled (or the given) tools",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			return tools.UpdateLock(cmd.Context(), log, cfg, tools.UpdateOptions{Offline: offline}, args)
+		},
+	}
+
+	lockCmd.AddCommand(updateCmd)
+	return lockCmd
+}
@@ -1,24 +1,49 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
 	"kindctl/internal/cluster"
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
+	"kindctl/internal/retry"
 	"kindctl/internal/tools"
 )
 
 var (
-	configFile  string
+	configFiles []string
+	profiles    []string
+	offline     bool
+	parallel    int
+	keepGoing   bool
+	timeout     time.Duration
+	stepTimeout time.Duration
+	retries     int
+	backoff     time.Duration
 	logLevel    string
 	version     = "dev"
 	showVersion bool
 )
 
 func main() {
+	// The first SIGINT or SIGTERM cancels the running command so it can clean
+	// up; a second one terminates kindctl right away.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	var cancelTimeout context.CancelFunc = func() {}
 	rootCmd := &cobra.Command{
 		Use:   "kindctl",
 		Short: "kindctl is a CLI tool to manage local Kubernetes clusters using Kind",
@@ -27,45 +52,42 @@ func main() {
 				fmt.Printf("kindctl version %s\n", version)
 				os.Exit(0)
 			}
+			var ctx context.Context
+			ctx, cancelTimeout = withTimeout(cmd.Context())
+			cmd.SetContext(withRetries(ctx))
 		},
 	}
 
-	rootCmd.PersistentFlags().StringVarP(&configFile, "config", "c", "kindctl.yaml", "Path to configuration file")
+	rootCmd.PersistentFlags().StringArrayVarP(&configFiles, "config", "c", nil, "Path to configuration file, repeat to merge several files (default: $KINDCTL_CONFIG or kindctl.yaml in the current or a parent directory)")
+	rootCmd.PersistentFlags().StringSliceVar(&profiles, "profile", nil, "Profile from the config file to apply (repeatable, defaults to $"+config.EnvProfile+")")
+	rootCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Fail instead of downloading manifests or charts; use embedded manifests and the chart cache")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Abort the command after this long, e.g. 15m (default: no limit)")
+	rootCmd.PersistentFlags().DurationVar(&stepTimeout, "step-timeout", 10*time.Minute, "Abort a single step, such as installing one tool or creating the cluster, after this long (0 for no limit)")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 3, "Retry Kubernetes API and Helm requests, kind and docker calls and downloads this often when they fail with a transient error (0 to disable)")
+	rootCmd.PersistentFlags().DurationVar(&backoff, "retry-backoff", 2*time.Second, "Delay before the first retry; it doubles with every further retry")
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "info", "Log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().BoolVarP(&showVersion, "version", "v", false, "Print the version of kindctl")
 
-	initCmd := &cobra.Command{
-		Use:   "init",
-		Short: "Initialize a new Kind cluster and create a default config file",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			log := logger.NewLogger(logLevel)
-			return cluster.Initialize(log, configFile)
-		},
-	}
-
 	updateCmd := &cobra.Command{
 		Use:   "update",
 		Short: "Update the Kind cluster with tools specified in the config file",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			log := logger.NewLogger(logLevel)
-			cfg, err := config.LoadConfig(configFile)
-			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
-			}
-			return tools.UpdateCluster(log, cfg)
+			return runUpdate(cmd.Context(), logger.NewLogger(logLevel))
 		},
 	}
+	updateCmd.Flags().IntVar(&parallel, "parallel", 4, "Number of tools to install at the same time")
+	updateCmd.Flags().BoolVar(&keepGoing, "continue-on-error", false, "Attempt every tool even after one fails and report all failures")
 
 	destroyCmd := &cobra.Command{
 		Use:   "destroy",
 		Short: "Delete the Kind cluster",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			log := logger.NewLogger(logLevel)
-			cfg, err := config.LoadConfig(configFile)
+			cfg, err := loadConfig(log)
 			if err != nil {
-				return fmt.Errorf("failed to load config: %w", err)
+				return err
 			}
-			return cluster.Destroy(log, cfg.Cluster.Name)
+			return cluster.Destroy(cmd.Context(), log, cfg)
 		},
 	}
 
@@ -77,14 +99,33 @@ func main() {
 		},
 	}
 
-	rootCmd.AddCommand(initCmd, updateCmd, destroyCmd, versionCmd)
+	rootCmd.AddCommand(newInitCmd(), updateCmd, destroyCmd, versionCmd, newConfigCmd(), newDashboardCmd(), newCacheCmd(), newImagesCmd(), newLockCmd(), newRenderCmd(), newDevCmd(), newImportCmd(),
+		newPluginsCmd(), newStatusCmd(), newToggleCmd("enable", true), newToggleCmd("disable", false))
+	if ran, err := runPlugin(ctx, rootCmd, os.Args[1:]); ran {
+		if err != nil {
+			// A failing plugin reports its own errors.
+			if _, ok := err.(*exec.ExitError); !ok {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(exitCode(err))
+		}
+		os.Exit(0)
+	}
 	for _, arg := range os.Args[1:] {
 		if arg == "--version" || arg == "-v" {
 			fmt.Printf("kindctl version: %s\n", version)
 			os.Exit(0)
 		}
 	}
-	if err := rootCmd.Execute(); err != nil {
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	cancelTimeout()
+	if err != nil {
+		if cause := context.Cause(cmd.Context()); cause != nil {
+			if errors.Is(cause, context.Canceled) {
+				cause = errors.New("interrupted")
+			}
+			err = fmt.Errorf("%w (%v)", err, cause)
+		}
 		_, err := fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		if err != nil {
 			return
@@ -92,3 +133,38 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// withTimeout applies the --timeout flag to ctx.
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("timed out after %s", timeout))
+}
+
+// withRetries applies the --retries and --retry-backoff flags to ctx.
+func withRetries(ctx context.Context) context.Context {
+	log := logger.NewLogger(logLevel)
+	return retry.WithPolicy(ctx, retry.Policy{
+		Attempts:   retries + 1,
+		Backoff:    backoff,
+		MaxBackoff: retry.DefaultPolicy.MaxBackoff,
+		OnRetry: func(err error, attempt int, delay time.Duration) {
+			log.Warnf("Retrying in %s after transient error (attempt %d of %d): %v", delay, attempt, retries+1, err)
+		},
+	})
+}
+
+// runUpdate installs or updates the tools of the effective configuration.
+func runUpdate(ctx context.Context, log *logger.Logger) error {
+	cfg, err := loadConfig(log)
+	if err != nil {
+		return err
+	}
+	return tools.UpdateCluster(ctx, log, cfg, tools.UpdateOptions{
+		Offline:         offline,
+		Parallel:        parallel,
+		StepTimeout:     stepTimeout,
+		ContinueOnError: keepGoing,
+	})
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/cluster"
+	"kindctl/internal/command"
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+)
+
+// pluginPrefix is the prefix of executables on PATH that extend kindctl.
+const pluginPrefix = "kindctl-"
+
+// plugin is an executable found on PATH.
+type plugin struct {
+	Name string
+	Path string
+	// Shadowed lists executables of the same name later on PATH.
+	Shadowed []string
+}
+
+// findPlugins scans PATH for kindctl-<name> executables.
+func findPlugins() []plugin {
+	var plugins []plugin
+	index := map[string]int{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok || entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if !isExecutable(path) {
+				continue
+			}
+			if i, seen := index[name]; seen {
+				plugins[i].Shadowed = append(plugins[i].Shadowed, path)
+				continue
+			}
+			index[name] = len(plugins)
+			plugins = append(plugins, plugin{Name: name, Path: path})
+		}
+	}
+	sort.Slice(plugins, func(i, j int) bool { return plugins[i].Name < plugins[j].Name })
+	return plugins
+}
+
+// pluginName returns the subcommand provided by an executable file name.
+func pluginName(file string) (string, bool) {
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(file))
+		if ext != ".exe" && ext != ".bat" && ext != ".cmd" {
+			return "", false
+		}
+		file = strings.TrimSuffix(file, filepath.Ext(file))
+	}
+	name := strings.TrimPrefix(file, pluginPrefix)
+	if name == file || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
+
+// runPlugin executes the plugin named by the first argument when it is not a
+// built-in command. It reports whether a plugin was run; the process exits
+// with the plugin's exit code.
+func runPlugin(ctx context.Context, rootCmd *cobra.Command, args []string) (bool, error) {
+	if _, _, err := rootCmd.Find(args); err == nil {
+		return false, nil
+	}
+	flags := rootCmd.PersistentFlags()
+	flags.SetInterspersed(false)
+	defer flags.SetInterspersed(true)
+	if err := flags.Parse(args); err != nil || flags.NArg() == 0 {
+		return false, nil
+	}
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+	ctx = withRetries(ctx)
+	name := flags.Arg(0)
+	if strings.HasPrefix(name, "-") {
+		return false, nil
+	}
+	path, err := exec.LookPath(pluginPrefix + name)
+	if err != nil {
+		return false, nil
+	}
+
+	log := logger.NewLogger(logLevel)
+	env, cleanup, err := pluginEnv(ctx, log)
+	if err != nil {
+		return true, err
+	}
+	defer cleanup()
+
+	cmd := command.New(ctx, path, flags.Args()[1:]...)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	log.Debugf("Running plugin %s", path)
+	return true, cmd.Run()
+}
+
+// pluginEnv describes the configuration and cluster to a plugin:
+//
+//	KINDCTL_CONFIG       the configuration file
+//	KINDCTL_CONFIG_JSON  a file with the effective configuration as JSON
+//	KINDCTL_PROFILE      the applied profiles, comma separated
+//	KINDCTL_CLUSTER      the Kind cluster name
+//	KINDCTL_LOG_LEVEL    the log level
+//	KUBECONFIG           a kubeconfig for the cluster, if it exists
+//
+// The returned function removes the temporary files.
+func pluginEnv(ctx context.Context, log *logger.Logger) ([]string, func(), error) {
+	var temps []string
+	cleanup := func() {
+		for _, path := range temps {
+			_ = os.Remove(path)
+		}
+	}
+	writeTemp := func(pattern string, data []byte) (string, error) {
+		f, err := os.CreateTemp("", pattern)
+		if err != nil {
+			return "", err
+		}
+		temps = append(temps, f.Name())
+		_, err = f.Write(data)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return f.Name(), err
+	}
+
+	env := []string{"KINDCTL_LOG_LEVEL=" + logLevel}
+	layered, err := loadLayered(log)
+	if err != nil {
+		// Plugins may not need a configuration, e.g. to create one.
+		log.Debugf("Running plugin without configuration: %v", err)
+		return env, cleanup, nil
+	}
+	cfg := layered.Config
+	data, err := cfg.JSON()
+	if err != nil {
+		return nil, cleanup, err
+	}
+	path, err := writeTemp("kindctl-config-*.json", data)
+	if err != nil {
+		cleanup()
+		return nil, cleanup, err
+	}
+	file, err := filepath.Abs(layered.Files[0])
+	if err != nil {
+		cleanup()
+		return nil, cleanup, err
+	}
+	env = append(env,
+		config.EnvConfig+"="+file,
+		"KINDCTL_CONFIG_JSON="+path,
+		config.EnvProfile+"="+strings.Join(layered.Profiles, ","),
+		"KINDCTL_CLUSTER="+cfg.Cluster.Name)
+
+	kubeconfig, err := cluster.Kubeconfig(ctx, cfg.Cluster.Name)
+	if err != nil {
+		log.Debugf("Not passing a kubeconfig to the plugin: %v", err)
+		return env, cleanup, nil
+	}
+	path, err = writeTemp("kindctl-kubeconfig-*", kubeconfig)
+	if err != nil {
+		cleanup()
+		return nil, cleanup, err
+	}
+	return append(env, "KUBECONFIG="+path), cleanup, nil
+}
+
+// exitCode returns the exit code to report for a failed plugin.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+func newPluginsCmd() *cobra.Command {
+	pluginsCmd := &cobra.Command{
+		Use:   "plugins",
+		Short: "Work with kindctl plugins",
+		Long: `Executables named kindctl-<name> on PATH run as "kindctl <name>". They
+receive the configuration file, the effective configuration as JSON, the
+cluster name and a kubeconfig for the cluster through KINDCTL_CONFIG,
+KINDCTL_CONFIG_JSON, KINDCTL_CLUSTER and KUBECONFIG.`,
+	}
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the plugins found on PATH",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			plugins := findPlugins()
+			if len(plugins) == 0 {
+				log.Infof("No plugins found on PATH")
+				return nil
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tPATH")
+			for _, p := range plugins {
+				fmt.Fprintf(w, "%s\t%s\n", p.Name, p.Path)
+			}
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			for _, p := range plugins {
+				if c, _, err := cmd.Root().Find([]string{p.Name}); err == nil && c != cmd.Root() {
+					log.Warnf("Plugin %s is shadowed by the built-in %s command", p.Path, p.Name)
+				}
+				for _, path := range p.Shadowed {
+					log.Warnf("Plugin %s is shadowed by %s", path, p.Path)
+				}
+			}
+			return nil
+		},
+	}
+
+	pluginsCmd.AddCommand(listCmd)
+	return pluginsCmd
+}
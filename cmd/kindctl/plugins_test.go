@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"kindctl/internal/config"
+)
+
+// writeStub writes an executable shell script.
+func writeStub(t *testing.T, dir, name, script string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+	return path
+}
+
+func TestFindPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are batch files on Windows")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	hello := writeStub(t, first, "kindctl-hello", "")
+	shadowed := writeStub(t, second, "kindctl-hello", "")
+	other := writeStub(t, second, "kindctl-other", "")
+	assert.NoError(t, os.WriteFile(filepath.Join(first, "kindctl-data"), nil, 0644))
+	writeStub(t, first, "kindctl-", "")
+	writeStub(t, first, "kubectl-hello", "")
+	t.Setenv("PATH", first+string(os.PathListSeparator)+second)
+
+	assert.Equal(t, []plugin{
+		{Name: "hello", Path: hello, Shadowed: []string{shadowed}},
+		{Name: "other", Path: other},
+	}, findPlugins())
+}
+
+func TestRunPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are batch files on Windows")
+	}
+	bin, dir := t.TempDir(), t.TempDir()
+	// The plugin records its arguments and environment with shell builtins
+	// only, as PATH holds nothing else, and exits with the code it is given.
+	writeStub(t, bin, "kindctl-hello", `out=$1; shift
+{
+	echo "args=$*"
+	echo "KINDCTL_CONFIG=$KINDCTL_CONFIG"
+	echo "KINDCTL_CONFIG_JSON=$KINDCTL_CONFIG_JSON"
+	echo "KINDCTL_CLUSTER=$KINDCTL_CLUSTER"
+	echo "KINDCTL_LOG_LEVEL=$KINDCTL_LOG_LEVEL"
+	while IFS= read -r line; do echo "$line"; done < "$KINDCTL_CONFIG_JSON"
+} > "$out"
+exit $1
+`)
+	t.Setenv("PATH", bin)
+	cfgPath := filepath.Join(dir, config.FileName)
+	assert.NoError(t, os.WriteFile(cfgPath, []byte("cluster:\n  name: plugin-test\n"), 0644))
+	t.Setenv(config.EnvConfig, cfgPath)
+	t.Setenv(config.EnvProfile, "")
+	saved := logLevel
+	logLevel = "error"
+	defer func() { logLevel = saved }()
+
+	root := &cobra.Command{Use: "kindctl"}
+	root.AddCommand(&cobra.Command{Use: "render", Run: func(*cobra.Command, []string) {}})
+
+	ran, err := runPlugin(context.Background(), root, []string{"render"})
+	assert.False(t, ran, "built-in commands take precedence")
+	assert.NoError(t, err)
+	ran, err = runPlugin(context.Background(), root, []string{"missing"})
+	assert.False(t, ran)
+	assert.NoError(t, err)
+
+	out := filepath.Join(dir, "out")
+	ran, err = runPlugin(context.Background(), root, []string{"hello", out, "0", "--flag"})
+	assert.True(t, ran)
+	assert.NoError(t, err)
+	data, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	output := string(data)
+	assert.Contains(t, output, "args=0 --flag\n")
+	assert.Contains(t, output, "KINDCTL_CONFIG="+cfgPath+"\n")
+	assert.Contains(t, output, "KINDCTL_CLUSTER=plugin-test\n")
+	assert.Contains(t, output, "KINDCTL_LOG_LEVEL=error\n")
+	assert.Contains(t, output, `"name": "plugin-test"`)
+	for _, line := range strings.Split(output, "\n") {
+		if path, ok := strings.CutPrefix(line, "KINDCTL_CONFIG_JSON="); ok {
+			assert.NoFileExists(t, path, "the configuration JSON is removed after the plugin exits")
+		}
+	}
+
+	ran, err = runPlugin(context.Background(), root, []string{"hello", out, "3"})
+	assert.True(t, ran)
+	assert.Error(t, err)
+	assert.Equal(t, 3, exitCode(err))
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newRenderCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "render [tool]...",
+		Short: "Print the manifests of the enabled (or the given) tools without installing them",
+		Long: `Print the manifests of the enabled (or the given) tools without installing them.
+
+Charts are rendered with the values and valuesFiles of the configuration
+merged over the values kindctl generates, in the versions of kindctl.lock.
+Images are shown by tag, without the digests of kindctl.lock they are
+pinned to on install, and the Ingress objects and dashboard ServiceAccount
+kindctl applies next to the tools are not included.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			rendered, err := tools.Render(cmd.Context(), log, cfg, tools.UpdateOptions{Offline: offline}, args)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			for _, tm := range rendered {
+				fmt.Fprintf(out, "---\n# Tool: %s\n", tm.Tool)
+				out.Write(bytes.TrimPrefix(bytes.TrimSpace(tm.Manifest), []byte("---\n")))
+				fmt.Fprintln(out)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+func newStatusCmd() *cobra.Command {
+	var showValues bool
+	statusCmd := &cobra.Command{
+		Use:   "status [tool]...",
+		Short: "Show the Helm releases of the enabled (or the given) chart-based tools",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log := logger.NewLogger(logLevel)
+			cfg, err := loadConfig(log)
+			if err != nil {
+				return err
+			}
+			if showValues {
+				if len(args) != 1 {
+					return fmt.Errorf("--values needs exactly one tool")
+				}
+				values, err := tools.ReleaseValues(cmd.Context(), log, cfg, args[0])
+				if err != nil {
+					return err
+				}
+				return yaml.NewEncoder(cmd.OutOrStdout()).Encode(values)
+			}
+			statuses, err := tools.ReleaseStatuses(cmd.Context(), log, cfg, args)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TOOL\tNAMESPACE\tSTATUS\tREVISION\tCHART\tUPDATED")
+			for _, s := range statuses {
+				revision, chart, updated := "-", "-", "-"
+				if s.Revision > 0 {
+					revision = fmt.Sprint(s.Revision)
+					chart = s.Chart
+					updated = s.Updated.Local().Format(time.DateTime)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Tool, s.Namespace, s.Status, revision, chart, updated)
+			}
+			return w.Flush()
+		},
+	}
+	statusCmd.Flags().BoolVar(&showValues, "values", false, "Print the values the release of the given tool was installed with")
+	return statusCmd
+}
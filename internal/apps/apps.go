@@ -0,0 +1,359 @@
+// Package apps builds the services under development, loads their images
+// into the cluster and deploys them next to the tools.
+package apps
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"kindctl/internal/command"
+	"kindctl/internal/config"
+	"kindctl/internal/helm"
+	"kindctl/internal/images"
+	"kindctl/internal/kube"
+	"kindctl/internal/logger"
+	"kindctl/internal/tools"
+)
+
+// defaultPort is the container port of generated Deployments.
+const defaultPort = 8080
+
+// Find returns the apps with the given names, or all apps if names is empty.
+func Find(cfg *config.Config, names []string) ([]config.App, error) {
+	if len(names) == 0 {
+		if len(cfg.Apps) == 0 {
+			return nil, fmt.Errorf("no apps configured")
+		}
+		return cfg.Apps, nil
+	}
+	var found []config.App
+	for _, name := range names {
+		app, ok := lookup(cfg, name)
+		if !ok {
+			return nil, fmt.Errorf("unknown app %q", name)
+		}
+		found = append(found, app)
+	}
+	return found, nil
+}
+
+func lookup(cfg *config.Config, name string) (config.App, bool) {
+	for _, app := range cfg.Apps {
+		if app.Name == name {
+			return app, true
+		}
+	}
+	return config.App{}, false
+}
+
+// buildContext returns the app's build context.
+func buildContext(cfg *config.Config, app config.App) string {
+	if app.Context == "" {
+		return cfg.Path(".")
+	}
+	return cfg.Path(app.Context)
+}
+
+// Build builds the app's image and makes it available to the cluster, by
+// pushing it to the local registry when enabled or loading it into the
+// nodes otherwise. It returns the image reference.
+func Build(ctx context.Context, log *logger.Logger, cfg *config.Config, app config.App) (string, error) {
+	repository := "kindctl.local/" + app.Name
+	if cfg.Registry.Enabled {
+		repository = fmt.Sprintf("localhost:%d/%s", cfg.Registry.Port, app.Name)
+	}
+	image := fmt.Sprintf("%s:dev-%d", repository, time.Now().Unix())
+
+	args := []string{"build", "-t", image}
+	if app.Dockerfile != "" {
+		args = append(args, "-f", filepath.Join(buildContext(cfg, app), app.Dockerfile))
+	}
+	keys := make([]string, 0, len(app.BuildArgs))
+	for key := range app.BuildArgs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	// Build arguments may hold secrets, so their values are passed through
+	// the environment rather than the command line.
+	env := os.Environ()
+	for _, key := range keys {
+		args = append(args, "--build-arg", key)
+		env = append(env, key+"="+app.BuildArgs[key])
+	}
+	err := command.Run(ctx, func() *exec.Cmd {
+		cmd := command.New(ctx, "docker", append(args, buildContext(cfg, app))...)
+		cmd.Env = env
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Debugf("Running %s", command.String(cmd))
+		return cmd
+	})
+	if err != nil {
+		return "", fmt.Errorf("building %s: %w", app.Name, err)
+	}
+	log.Infof("Built image %s", image)
+
+	if cfg.Registry.Enabled {
+		err := command.Run(ctx, func() *exec.Cmd {
+			cmd := command.New(ctx, "docker", "push", image)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			return cmd
+		})
+		if err != nil {
+			return "", fmt.Errorf("pushing %s: %w", image, err)
+		}
+		return image, nil
+	}
+	return image, images.Load(ctx, log, cfg.Cluster.Name, []string{image})
+}
+
+// Deploy rolls out the app with the given image.
+func Deploy(ctx context.Context, log *logger.Logger, cfg *config.Config, app config.App, image string) error {
+	env, err := renderEnv(app.Env, tools.Connections(cfg))
+	if err != nil {
+		return fmt.Errorf("%s: %w", app.Name, err)
+	}
+
+	switch {
+	case app.Chart != "":
+		err = deployChart(ctx, log, cfg, cfg.Path(app.Chart), app, image, env)
+	case app.Manifests != "":
+		var manifest []byte
+		manifest, err = tools.ReadManifests(cfg.Path(app.Manifests))
+		if err == nil {
+			manifest, err = patchManifest(manifest, app.Name, image, env)
+		}
+		if err == nil {
+			err = apply(ctx, cfg, manifest)
+		}
+	default:
+		err = apply(ctx, cfg, generatedManifest(app, image, env))
+	}
+	if err != nil {
+		return fmt.Errorf("deploying %s: %w", app.Name, err)
+	}
+
+	if app.Ingress != "" {
+		if err := apply(ctx, cfg, ingressManifest(app)); err != nil {
+			return fmt.Errorf("deploying ingress of %s: %w", app.Name, err)
+		}
+	}
+	log.Infof("Deployed %s with image %s", app.Name, image)
+	return nil
+}
+
+// renderEnv expands the env templates with the tools' connection details.
+func renderEnv(env map[string]string, conns map[string]map[string]string) ([]envVar, error) {
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	vars := make([]envVar, 0, len(env))
+	for _, key := range keys {
+		tmpl, err := template.New(key).Option("missingkey=error").Parse(env[key])
+		if err != nil {
+			return nil, fmt.Errorf("env %s: %w", key, err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, conns); err != nil {
+			return nil, fmt.Errorf("env %s: %w (is the tool enabled?)", key, err)
+		}
+		vars = append(vars, envVar{Name: key, Value: buf.String()})
+	}
+	return vars, nil
+}
+
+type envVar struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value"`
+}
+
+// patchManifest points every container whose image is the app name at the
+// built image and adds the env vars to it.
+func patchManifest(manifest []byte, name, image string, env []envVar) ([]byte, error) {
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	dec := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			continue
+		}
+		patchContainers(&doc, name, image, env)
+		if err := enc.Encode(&doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func patchContainers(node *yaml.Node, name, image string, env []envVar) {
+	if node.Kind == yaml.MappingNode {
+		var imageNode, envNode *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			switch node.Content[i].Value {
+			case "image":
+				imageNode = node.Content[i+1]
+			case "env":
+				envNode = node.Content[i+1]
+			}
+		}
+		if imageNode != nil && imageNode.Kind == yaml.ScalarNode && imageNode.Value == name {
+			imageNode.Value = image
+			if len(env) > 0 {
+				if envNode == nil {
+					envNode = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+					node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "env"}, envNode)
+				}
+				for _, v := range env {
+					var item yaml.Node
+					_ = item.Encode(v)
+					envNode.Content = append(envNode.Content, &item)
+				}
+			}
+			return
+		}
+	}
+	for _, child := range node.Content {
+		patchContainers(child, name, image, env)
+	}
+}
+
+// generatedManifest returns a Deployment and Service running the image.
+func generatedManifest(app config.App, image string, env []envVar) []byte {
+	port := app.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	var envYAML strings.Builder
+	if len(env) > 0 {
+		envYAML.WriteString("        env:\n")
+		for _, v := range env {
+			fmt.Fprintf(&envYAML, "        - name: %s\n          value: %s\n", v.Name, strconv.Quote(v.Value))
+		}
+	}
+	return []byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ` + app.Name + `
+  namespace: default
+spec:
+  selector:
+    matchLabels:
+      app: ` + app.Name + `
+  template:
+    metadata:
+      labels:
+        app: ` + app.Name + `
+    spec:
+      containers:
+      - name: ` + app.Name + `
+        image: ` + image + `
+        ports:
+        - containerPort: ` + strconv.Itoa(port) + `
+` + envYAML.String() + `---
+apiVersion: v1
+kind: Service
+metadata:
+  name: ` + app.Name + `
+  namespace: default
+spec:
+  selector:
+    app: ` + app.Name + `
+  ports:
+  - port: 80
+    targetPort: ` + strconv.Itoa(port) + `
+`)
+}
+
+// ingressManifest routes the app's ingress host to its Service.
+func ingressManifest(app config.App) []byte {
+	return []byte(`
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: ` + app.Name + `-ingress
+  namespace: default
+spec:
+  rules:
+  - host: ` + app.Ingress + `
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: ` + app.Name + `
+            port:
+              number: 80
+`)
+}
+
+// chartValues returns the values of the app's chart: its configured values
+// with image.repository and image.tag pointing at the built image and, if
+// any, the rendered env vars as env, a list of name/value pairs.
+func chartValues(app config.App, image string, env []envVar) map[string]interface{} {
+	repository, tag := image, ""
+	if i := strings.LastIndex(image, ":"); i > 0 {
+		repository, tag = image[:i], image[i+1:]
+	}
+	overrides := map[string]interface{}{
+		"image": map[string]interface{}{"repository": repository, "tag": tag},
+	}
+	if len(env) > 0 {
+		list := make([]interface{}, len(env))
+		for i, v := range env {
+			list[i] = map[string]interface{}{"name": v.Name, "value": v.Value}
+		}
+		overrides["env"] = list
+	}
+	return helm.MergeValues(app.Values, overrides)
+}
+
+// deployChart installs or upgrades the app's local chart.
+func deployChart(ctx context.Context, log *logger.Logger, cfg *config.Config, chart string, app config.App, image string, env []envVar) error {
+	client, err := helm.New(cfg.Cluster.Name, log.Debugf)
+	if err != nil {
+		return err
+	}
+	loaded, err := client.Load(ctx, chart, "")
+	if err != nil {
+		return err
+	}
+	_, _, err = client.Upgrade(ctx, helm.Release{Name: app.Name, Namespace: "default", Chart: loaded, Values: chartValues(app, image, env)})
+	return err
+}
+
+func apply(ctx context.Context, cfg *config.Config, manifest []byte) error {
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
+		return err
+	}
+	return client.Apply(ctx, manifest, os.Stdout)
+}
@@ -0,0 +1,118 @@
+package apps
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kindctl/internal/config"
+	"kindctl/internal/ingress"
+	"kindctl/internal/logger"
+)
+
+// pollInterval is how often watched files are checked for changes.
+const pollInterval = time.Second
+
+// Dev builds and deploys the apps, then keeps rebuilding and redeploying
+// each app whenever one of its watched files changes. With watch unset it
+// returns after the first deployment.
+func Dev(ctx context.Context, log *logger.Logger, cfg *config.Config, apps []config.App, watch bool) error {
+	snapshots := make([]map[string]time.Time, len(apps))
+	for i, app := range apps {
+		snapshots[i] = snapshot(watchPaths(cfg, app))
+		if err := buildAndDeploy(ctx, log, cfg, app); err != nil {
+			return err
+		}
+		if app.Ingress != "" {
+			if err := ingress.AddHostEntry(ctx, log, app.Ingress); err != nil {
+				log.Warnf("Failed to add /etc/hosts entry for %s: %v", app.Ingress, err)
+			}
+		}
+	}
+	if !watch {
+		return nil
+	}
+
+	log.Info("👀 Watching for changes, press Ctrl-C to stop")
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Infof("Stopped watching")
+			return nil
+		case <-ticker.C:
+		}
+		for i, app := range apps {
+			current := snapshot(watchPaths(cfg, app))
+			if equal(current, snapshots[i]) {
+				continue
+			}
+			snapshots[i] = current
+			log.Infof("Change detected in %s, rebuilding", app.Name)
+			// Keep watching after a failed build so the next fix is picked up.
+			if err := buildAndDeploy(ctx, log, cfg, app); err != nil {
+				log.Errorf("%v", err)
+			}
+		}
+	}
+}
+
+func buildAndDeploy(ctx context.Context, log *logger.Logger, cfg *config.Config, app config.App) error {
+	image, err := Build(ctx, log, cfg, app)
+	if err != nil {
+		return err
+	}
+	return Deploy(ctx, log, cfg, app, image)
+}
+
+func watchPaths(cfg *config.Config, app config.App) []string {
+	if len(app.Watch) == 0 {
+		return []string{buildContext(cfg, app)}
+	}
+	paths := make([]string, len(app.Watch))
+	for i, path := range app.Watch {
+		paths[i] = cfg.Path(path)
+	}
+	return paths
+}
+
+// snapshot records the modification time of every file below the paths,
+// skipping hidden directories such as .git as well as node_modules.
+func snapshot(paths []string) map[string]time.Time {
+	files := map[string]time.Time{}
+	for _, root := range paths {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if path != root && (strings.HasPrefix(name, ".") || name == "node_modules") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info, err := os.Stat(path); err == nil {
+				files[path] = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return files
+}
+
+func equal(a, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, modTime := range a {
+		if other, ok := b[path]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
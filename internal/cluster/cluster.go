@@ -1,35 +1,71 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
+	"kindctl/internal/command"
 	"kindctl/internal/config"
+	"kindctl/internal/kube"
 	"kindctl/internal/logger"
+	"kindctl/internal/manifests"
 )
 
-func Initialize(log *logger.Logger, configFile string) error {
+// InitOptions controls what Initialize sets up.
+type InitOptions struct {
+	Config config.Options
+	// Tools are enabled in a newly written configuration file.
+	Tools []string
+	// NoCluster only writes the configuration file.
+	NoCluster bool
+	// Offline uses the embedded manifests only.
+	Offline bool
+	// StepTimeout bounds each step of the setup, such as creating the
+	// cluster; zero means no limit.
+	StepTimeout time.Duration
+}
+
+// stepContext bounds one step of the setup by timeout, if set.
+func stepContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, timeout, fmt.Errorf("step timed out after %s", timeout))
+}
+
+func Initialize(ctx context.Context, log *logger.Logger, opts InitOptions) error {
+	configFile := opts.Config.Files[0]
 	if _, err := os.Stat(configFile); err == nil {
 		log.Info("kindctl.yaml file already exists.")
 	} else if os.IsNotExist(err) {
-		cfg := config.DefaultConfig()
-		if err := config.SaveConfig(configFile, cfg); err != nil {
+		data, err := config.Generate(config.DefaultConfig().Cluster.Name, opts.Tools)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(configFile, data, 0644); err != nil {
 			return err
 		}
 		log.Info("✅ Created default configuration file: ", configFile)
 	} else {
 		return err
 	}
+	if opts.NoCluster {
+		return nil
+	}
 
-	cfg, err := config.LoadConfig(configFile)
+	layered, err := config.Load(opts.Config)
 	if err != nil {
 		return err
 	}
+	cfg := layered.Config
 
-	cmd := exec.Command("kind", "get", "clusters")
-	output, err := cmd.CombinedOutput()
+	output, err := command.Output(ctx, func() *exec.Cmd {
+		return command.New(ctx, "kind", "get", "clusters")
+	})
 	if err != nil {
 		return err
 	}
@@ -41,34 +77,94 @@ func Initialize(log *logger.Logger, configFile string) error {
 		}
 	}
 
-	cmd = exec.Command("kind", "create", "cluster", "--name", cfg.Cluster.Name)
+	if cfg.Registry.Enabled {
+		if err := ensureRegistry(ctx, log, cfg); err != nil {
+			return err
+		}
+	}
+	if err := ensureMirrorCaches(ctx, log, cfg); err != nil {
+		return err
+	}
+
+	kindConfigFile, err := writeKindConfig(cfg)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(kindConfigFile)
+	createCtx, cancel := stepContext(ctx, opts.StepTimeout)
+	defer cancel()
+	cmd := command.New(createCtx, "kind", "create", "cluster", "--name", cfg.Cluster.Name, "--config", kindConfigFile)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		return err
+		return stepError(createCtx, err)
 	}
 	log.Info("✅ Created Kind cluster: ", cfg.Cluster.Name)
 
+	if cfg.Registry.Enabled {
+		if err := setupRegistry(ctx, log, cfg); err != nil {
+			return err
+		}
+	}
+	if err := setupMirrors(ctx, log, cfg); err != nil {
+		return err
+	}
+
+	if !cfg.IngressEnabled() {
+		log.Info("Not installing the NGINX ingress controller: cluster.ingress.enabled is false")
+		return nil
+	}
 	fmt.Println()
 	log.Info("🏗 Installing NGINX ingress controller...")
-	cmd = exec.Command("kubectl", "apply", "-f", "https://raw.githubusercontent.com/kubernetes/ingress-nginx/main/deploy/static/provider/kind/deploy.yaml")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+	ingressCtx, cancel := stepContext(ctx, opts.StepTimeout)
+	defer cancel()
+	manifest, err := manifests.IngressNginx.Load(ingressCtx, opts.Offline)
+	if err != nil {
+		return stepError(ingressCtx, err)
+	}
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
 		return err
 	}
+	if err := client.Apply(ingressCtx, manifest, os.Stdout); err != nil {
+		return stepError(ingressCtx, err)
+	}
 	log.Info("✅ Installed NGINX ingress controller")
 
 	return nil
 }
 
-func Destroy(log *logger.Logger, clusterName string) error {
-	cmd := exec.Command("kind", "delete", "cluster", "--name", clusterName)
+// stepError explains a failure caused by the step's deadline.
+func stepError(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil && ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: %v", cause, err)
+	}
+	return err
+}
+
+func Destroy(ctx context.Context, log *logger.Logger, cfg *config.Config) error {
+	clusterName := cfg.Cluster.Name
+	cmd := command.New(ctx, "kind", "delete", "cluster", "--name", clusterName)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
 		return err
 	}
 	log.Info("Deleted Kind cluster: ", clusterName)
+
+	if cfg.Registry.Enabled {
+		return removeRegistry(ctx, log, cfg)
+	}
 	return nil
 }
+
+// Kubeconfig returns the kubeconfig of the cluster.
+func Kubeconfig(ctx context.Context, clusterName string) ([]byte, error) {
+	output, err := command.Output(ctx, func() *exec.Cmd {
+		return command.New(ctx, "kind", "get", "kubeconfig", "--name", clusterName)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubeconfig of cluster %s: %w", clusterName, err)
+	}
+	return output, nil
+}
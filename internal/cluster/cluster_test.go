@@ -1,7 +1,8 @@
 package cluster
 
 import (
-	"os"
+	"context"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -18,16 +19,75 @@ func TestInitialize(t *testing.T) {
 	// Note: Actual kind cluster creation requires Docker, so we skip command execution in unit tests.
 	// This test verifies config saving logic. Integration tests cover cluster creation.
 	log := newTestLogger()
-	tmpFile, err := os.CreateTemp("", "kindctl.yaml")
-	assert.NoError(t, err)
-	defer os.Remove(tmpFile.Name())
+	configFile := filepath.Join(t.TempDir(), "kindctl.yaml")
 
-	err = Initialize(log, tmpFile.Name())
+	err := Initialize(context.Background(), log, InitOptions{
+		Config:    config.Options{Files: []string{configFile}},
+		Tools:     []string{"dashboard", "postgres"},
+		NoCluster: true,
+	})
 	assert.NoError(t, err)
 
 	// Verify config file was created
-	cfg, err := config.LoadConfig(tmpFile.Name())
+	cfg, err := config.LoadConfig(configFile)
 	assert.NoError(t, err)
 	assert.Equal(t, "kind-cluster", cfg.Cluster.Name)
 	assert.True(t, cfg.Dashboard.Enabled)
+	assert.True(t, cfg.Postgres.Enabled)
+	assert.False(t, cfg.Redis.Enabled)
+}
+
+func TestKindConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	data, err := kindConfig(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "ingress-ready=true")
+	assert.Contains(t, string(data), "- containerPort: 80\n          hostPort: 80\n")
+	assert.Contains(t, string(data), "- containerPort: 443\n          hostPort: 443\n")
+	assert.NotContains(t, string(data), "config_path")
+
+	cfg.Cluster.Ingress.HTTPPort, cfg.Cluster.Ingress.HTTPSPort = 8080, 8443
+	data, err = kindConfig(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "- containerPort: 80\n          hostPort: 8080\n")
+	assert.Contains(t, string(data), "- containerPort: 443\n          hostPort: 8443\n")
+
+	disabled := false
+	cfg.Cluster.Ingress.Enabled = &disabled
+	data, err = kindConfig(cfg)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "ingress-ready")
+	assert.NotContains(t, string(data), "extraPortMappings")
+
+	cfg.Registry.Enabled = true
+	data, err = kindConfig(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `config_path = "/etc/containerd/certs.d"`)
+}
+
+func TestMirrors(t *testing.T) {
+	t.Setenv("HUB_TOKEN", "s3cret")
+	cfg := config.DefaultConfig()
+	cfg.Cluster.Mirrors = []config.RegistryMirror{{
+		Registry:    "docker.io",
+		Endpoints:   []string{"https://mirror.example.com"},
+		Username:    "ci",
+		Password:    "${HUB_TOKEN}",
+		PullThrough: true,
+	}}
+
+	assert.Equal(t, `server = "https://registry-1.docker.io"
+
+[host."http://kind-mirror-docker-io:5000"]
+  capabilities = ["pull", "resolve"]
+
+[host."https://mirror.example.com"]
+  capabilities = ["pull", "resolve"]
+`, mirrorHostsToml(cfg.Cluster.Mirrors[0]))
+
+	data, err := kindConfig(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `config_path = "/etc/containerd/certs.d"`)
+	assert.Contains(t, string(data), `registry.configs."mirror.example.com".auth]`)
+	assert.Contains(t, string(data), `password = "s3cret"`)
 }
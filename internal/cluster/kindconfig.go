@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"os"
+
+	"gopkg.in/yaml.v3"
+	"kindctl/internal/config"
+)
+
+// certsDir is where containerd on the nodes looks up per-registry hosts.toml
+// files.
+const certsDir = "/etc/containerd/certs.d"
+
+// kindCluster is the subset of Kind's cluster configuration kindctl generates.
+type kindCluster struct {
+	Kind                    string     `yaml:"kind"`
+	APIVersion              string     `yaml:"apiVersion"`
+	ContainerdConfigPatches []string   `yaml:"containerdConfigPatches,omitempty"`
+	Nodes                   []kindNode `yaml:"nodes"`
+}
+
+type kindNode struct {
+	Role                 string        `yaml:"role"`
+	KubeadmConfigPatches []string      `yaml:"kubeadmConfigPatches,omitempty"`
+	ExtraPortMappings    []portMapping `yaml:"extraPortMappings,omitempty"`
+}
+
+type portMapping struct {
+	ContainerPort int `yaml:"containerPort"`
+	HostPort      int `yaml:"hostPort"`
+}
+
+// kindConfig generates the Kind cluster configuration. Unless the ingress is
+// turned off, the control-plane node is labelled for the NGINX ingress
+// controller and its ports 80 and 443 are mapped to the configured host
+// ports. Containerd reads registry hosts from certsDir.
+func kindConfig(cfg *config.Config) ([]byte, error) {
+	cluster := kindCluster{
+		Kind:       "Cluster",
+		APIVersion: "kind.x-k8s.io/v1alpha4",
+		Nodes:      []kindNode{{Role: "control-plane"}},
+	}
+	if cfg.IngressEnabled() {
+		node := &cluster.Nodes[0]
+		node.KubeadmConfigPatches = append(node.KubeadmConfigPatches, `kind: InitConfiguration
+nodeRegistration:
+  kubeletExtraArgs:
+    node-labels: "ingress-ready=true"
+`)
+		node.ExtraPortMappings = []portMapping{
+			{ContainerPort: 80, HostPort: orDefault(cfg.Cluster.Ingress.HTTPPort, 80)},
+			{ContainerPort: 443, HostPort: orDefault(cfg.Cluster.Ingress.HTTPSPort, 443)},
+		}
+	}
+	if cfg.Registry.Enabled || len(cfg.Cluster.Mirrors) > 0 {
+		cluster.ContainerdConfigPatches = append(cluster.ContainerdConfigPatches, `[plugins."io.containerd.grpc.v1.cri".registry]
+  config_path = "`+certsDir+`"
+`)
+	}
+	cluster.ContainerdConfigPatches = append(cluster.ContainerdConfigPatches, mirrorAuthPatches(cfg.Cluster.Mirrors)...)
+	return yaml.Marshal(cluster)
+}
+
+// orDefault returns port, or def if it is unset.
+func orDefault(port, def int) int {
+	if port == 0 {
+		return def
+	}
+	return port
+}
+
+// writeKindConfig writes the generated Kind configuration, which may hold
+// registry credentials, to a temporary file only readable by the user. The
+// caller removes it.
+func writeKindConfig(cfg *config.Config) (string, error) {
+	data, err := kindConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "kindctl-cluster-*.yaml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
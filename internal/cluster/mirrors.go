@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+)
+
+// upstreamURL returns the URL images of the registry are served from.
+func upstreamURL(registry string) string {
+	if registry == "docker.io" {
+		return "https://registry-1.docker.io"
+	}
+	return "https://" + registry
+}
+
+// cacheName is the name of the pull-through cache container for a registry.
+func cacheName(registry string) string {
+	return "kind-mirror-" + strings.NewReplacer(".", "-", ":", "-", "/", "-").Replace(registry)
+}
+
+// credentials returns the mirror's username and password with environment
+// references expanded.
+func credentials(m config.RegistryMirror) (string, string) {
+	return os.ExpandEnv(m.Username), os.ExpandEnv(m.Password)
+}
+
+// mirrorEndpoints returns the endpoints containerd tries for the mirror, the
+// local pull-through cache first.
+func mirrorEndpoints(m config.RegistryMirror) []string {
+	var endpoints []string
+	if m.PullThrough {
+		endpoints = append(endpoints, "http://"+cacheName(m.Registry)+":5000")
+	}
+	return append(endpoints, m.Endpoints...)
+}
+
+// mirrorHostsToml renders the containerd hosts.toml for a mirrored registry.
+func mirrorHostsToml(m config.RegistryMirror) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "server = %q\n", upstreamURL(m.Registry))
+	for _, endpoint := range mirrorEndpoints(m) {
+		fmt.Fprintf(&b, "\n[host.%q]\n  capabilities = [\"pull\", \"resolve\"]\n", endpoint)
+	}
+	return b.String()
+}
+
+// mirrorAuthPatches returns containerd config patches holding the
+// credentials of the mirror endpoints.
+func mirrorAuthPatches(mirrors []config.RegistryMirror) []string {
+	var patches []string
+	for _, m := range mirrors {
+		username, password := credentials(m)
+		if username == "" && password == "" {
+			continue
+		}
+		for _, endpoint := range m.Endpoints {
+			u, err := url.Parse(endpoint)
+			if err != nil || u.Host == "" {
+				continue
+			}
+			patches = append(patches, fmt.Sprintf("[plugins.\"io.containerd.grpc.v1.cri\".registry.configs.%q.auth]\n  username = %q\n  password = %q\n",
+				u.Host, username, password))
+		}
+	}
+	return patches
+}
+
+// ensureMirrorCaches starts the pull-through cache containers.
+func ensureMirrorCaches(ctx context.Context, log *logger.Logger, cfg *config.Config) error {
+	for _, m := range cfg.Cluster.Mirrors {
+		if !m.PullThrough {
+			continue
+		}
+		env := []string{"REGISTRY_PROXY_REMOTEURL=" + upstreamURL(m.Registry)}
+		if username, password := credentials(m); username != "" {
+			env = append(env, "REGISTRY_PROXY_USERNAME="+username, "REGISTRY_PROXY_PASSWORD="+password)
+		}
+		if err := ensureContainer(ctx, log, cacheName(m.Registry), env, "registry:2"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupMirrors points containerd on every node at the configured mirrors.
+func setupMirrors(ctx context.Context, log *logger.Logger, cfg *config.Config) error {
+	for _, m := range cfg.Cluster.Mirrors {
+		if m.PullThrough {
+			if err := connectToKindNetwork(ctx, cacheName(m.Registry)); err != nil {
+				return err
+			}
+		}
+		if err := configureNodes(ctx, cfg.Cluster.Name, m.Registry, mirrorHostsToml(m)); err != nil {
+			return err
+		}
+		log.Infof("✅ Configured mirrors for %s: %s", m.Registry, strings.Join(mirrorEndpoints(m), ", "))
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"kindctl/internal/command"
+	"kindctl/internal/config"
+	"kindctl/internal/kube"
+	"kindctl/internal/logger"
+)
+
+// kindNetwork is the Docker network Kind attaches its nodes to.
+const kindNetwork = "kind"
+
+// registryHost is the address the local registry is pushed to from the host
+// and pulled from inside the cluster.
+func registryHost(cfg *config.Config) string {
+	return fmt.Sprintf("localhost:%d", cfg.Registry.Port)
+}
+
+// ensureRegistry starts the local registry container unless it is running.
+func ensureRegistry(ctx context.Context, log *logger.Logger, cfg *config.Config) error {
+	return ensureContainer(ctx, log, cfg.Registry.Name, nil,
+		"-p", fmt.Sprintf("127.0.0.1:%d:5000", cfg.Registry.Port),
+		"registry:2")
+}
+
+// setupRegistry wires the running registry into a freshly created cluster:
+// it joins the Kind network, every node resolves registryHost to it, and the
+// local-registry-hosting ConfigMap advertises it to tooling.
+func setupRegistry(ctx context.Context, log *logger.Logger, cfg *config.Config) error {
+	if err := connectToKindNetwork(ctx, cfg.Registry.Name); err != nil {
+		return err
+	}
+	hostsToml := fmt.Sprintf("[host.\"http://%s:5000\"]\n", cfg.Registry.Name)
+	if err := configureNodes(ctx, cfg.Cluster.Name, registryHost(cfg), hostsToml); err != nil {
+		return err
+	}
+
+	configMap := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: local-registry-hosting
+  namespace: kube-public
+data:
+  localRegistryHosting.v1: |
+    host: "` + registryHost(cfg) + `"
+    help: "https://kind.sigs.k8s.io/docs/user/local-registry/"
+`
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
+		return err
+	}
+	if err := client.Apply(ctx, []byte(configMap), os.Stdout); err != nil {
+		return err
+	}
+	log.Infof("✅ Local registry available at %s", registryHost(cfg))
+	return nil
+}
+
+// removeRegistry deletes the local registry container.
+func removeRegistry(ctx context.Context, log *logger.Logger, cfg *config.Config) error {
+	if _, found := containerState(ctx, cfg.Registry.Name); !found {
+		return nil
+	}
+	cmd := command.New(ctx, "docker", "rm", "-f", cfg.Registry.Name)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	log.Infof("Deleted local registry: %s", cfg.Registry.Name)
+	return nil
+}
+
+// containerState reports whether a Docker container is running and whether
+// it exists at all.
+func containerState(ctx context.Context, name string) (running, found bool) {
+	output, err := command.New(ctx, "docker", "inspect", "-f", "{{.State.Running}}", name).Output()
+	if err != nil {
+		return false, false
+	}
+	return strings.TrimSpace(string(output)) == "true", true
+}
+
+// ensureContainer starts the named container, creating it with the given
+// docker run arguments if it does not exist. env holds NAME=value variables
+// of the container; they reach docker through its environment so that their
+// values, such as passwords, stay off the command line.
+func ensureContainer(ctx context.Context, log *logger.Logger, name string, env []string, runArgs ...string) error {
+	running, found := containerState(ctx, name)
+	if running {
+		log.Debugf("Container %s is already running", name)
+		return nil
+	}
+	attempt := 0
+	err := command.Run(ctx, func() *exec.Cmd {
+		// A failed attempt may have created the container, which then has
+		// to be started instead of run again under the same name.
+		if attempt++; attempt > 1 {
+			_, found = containerState(ctx, name)
+		}
+		var cmd *exec.Cmd
+		if found {
+			cmd = command.New(ctx, "docker", "start", name)
+		} else {
+			args := []string{"run", "-d", "--restart=always", "--name", name}
+			for _, variable := range env {
+				key, _, _ := strings.Cut(variable, "=")
+				args = append(args, "-e", key)
+			}
+			cmd = command.New(ctx, "docker", append(args, runArgs...)...)
+			cmd.Env = append(os.Environ(), env...)
+		}
+		log.Debugf("Running %s", command.String(cmd))
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd
+	})
+	if err != nil {
+		return fmt.Errorf("starting container %s: %w", name, err)
+	}
+	log.Infof("Started container %s", name)
+	return nil
+}
+
+// connectToKindNetwork attaches a container to the Kind network so the nodes
+// can reach it by name.
+func connectToKindNetwork(ctx context.Context, name string) error {
+	output, err := command.New(ctx, "docker", "inspect", "-f", "{{json .NetworkSettings.Networks}}", name).Output()
+	if err != nil {
+		return err
+	}
+	if strings.Contains(string(output), `"`+kindNetwork+`"`) {
+		return nil
+	}
+	cmd := command.New(ctx, "docker", "network", "connect", kindNetwork, name)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// configureNodes writes a containerd hosts.toml for registry on every node of
+// the cluster.
+func configureNodes(ctx context.Context, clusterName, registry, hostsToml string) error {
+	output, err := command.Output(ctx, func() *exec.Cmd {
+		return command.New(ctx, "kind", "get", "nodes", "--name", clusterName)
+	})
+	if err != nil {
+		return err
+	}
+	dir := certsDir + "/" + registry
+	for _, node := range strings.Fields(string(output)) {
+		err := command.Run(ctx, func() *exec.Cmd {
+			cmd := command.New(ctx, "docker", "exec", node, "mkdir", "-p", dir)
+			cmd.Stderr = os.Stderr
+			return cmd
+		})
+		if err != nil {
+			return err
+		}
+		err = command.Run(ctx, func() *exec.Cmd {
+			cmd := command.New(ctx, "docker", "exec", "-i", node, "cp", "/dev/stdin", dir+"/hosts.toml")
+			cmd.Stdin = bytes.NewBufferString(hostsToml)
+			cmd.Stderr = os.Stderr
+			return cmd
+		})
+		if err != nil {
+			return fmt.Errorf("configuring %s on node %s: %w", registry, node, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+// Package command runs external programs under a context.
+package command
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+	"unicode"
+
+	"kindctl/internal/retry"
+)
+
+// WaitDelay is how long a cancelled program may take to exit after being
+// interrupted before it is killed.
+const WaitDelay = 10 * time.Second
+
+// New returns a command that is interrupted when ctx is done, so helm,
+// kubectl and kind can clean up, and killed if it has not exited WaitDelay
+// later.
+func New(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Cancel = func() error {
+		if runtime.GOOS == "windows" {
+			return cmd.Process.Kill()
+		}
+		return cmd.Process.Signal(os.Interrupt)
+	}
+	cmd.WaitDelay = WaitDelay
+	return cmd
+}
+
+// secretWords mark environment variables and flags holding secrets when
+// they appear as a whole segment of the name, so that GITHUB_TOKEN and
+// --api-key match but BYPASS_CACHE and MONKEY do not.
+var secretWords = map[string]bool{
+	"PASSWORD": true, "PASSWD": true, "TOKEN": true, "SECRET": true, "KEY": true,
+	"APIKEY": true, "CREDENTIAL": true, "CREDENTIALS": true,
+}
+
+// isSecret reports whether a variable or flag name refers to a secret. The
+// name is split into segments at every character other than a letter or
+// digit.
+func isSecret(name string) bool {
+	segments := strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, segment := range segments {
+		if secretWords[segment] {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns args with the values of secret flags and NAME=value
+// assignments replaced, for echoing commands in logs.
+func Redact(args []string) []string {
+	redacted := make([]string, len(args))
+	hideNext := false
+	for i, arg := range args {
+		name, _, assigned := strings.Cut(arg, "=")
+		switch {
+		case hideNext:
+			redacted[i], hideNext = "***", false
+		case assigned && isSecret(name):
+			redacted[i] = name + "=***"
+		case !assigned && strings.HasPrefix(arg, "--") && isSecret(arg):
+			redacted[i], hideNext = arg, true
+		default:
+			redacted[i] = arg
+		}
+	}
+	return redacted
+}
+
+// String renders a command for logs with its secrets redacted.
+func String(cmd *exec.Cmd) string {
+	return strings.Join(Redact(cmd.Args), " ")
+}
+
+// Error is a failed command together with the last line of its error
+// output, which is what classifies it as transient or not.
+type Error struct {
+	Name   string
+	Err    error
+	Stderr string
+}
+
+func (e *Error) Error() string {
+	if e.Stderr == "" {
+		return e.Name + ": " + e.Err.Error()
+	}
+	return e.Name + ": " + e.Err.Error() + ": " + e.Stderr
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the command returned by build and retries it with a fresh command
+// while it fails with a transient error, following the retry policy of ctx.
+// build is called for every attempt so that stdin readers start over.
+func Run(ctx context.Context, build func() *exec.Cmd) error {
+	return retry.Do(ctx, func() error {
+		return run(build())
+	})
+}
+
+// Output is like Run and returns the standard output of the successful
+// attempt.
+func Output(ctx context.Context, build func() *exec.Cmd) ([]byte, error) {
+	var output []byte
+	err := retry.Do(ctx, func() error {
+		var stdout bytes.Buffer
+		cmd := build()
+		cmd.Stdout = &stdout
+		if err := run(cmd); err != nil {
+			return err
+		}
+		output = stdout.Bytes()
+		return nil
+	})
+	return output, err
+}
+
+func run(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	if cmd.Stderr != nil {
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, &stderr)
+	} else {
+		cmd.Stderr = &stderr
+	}
+	if err := cmd.Run(); err != nil {
+		return &Error{Name: filepath.Base(cmd.Path), Err: err, Stderr: lastLine(stderr.Bytes())}
+	}
+	return nil
+}
+
+// lastLine returns the last non-empty line of output.
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
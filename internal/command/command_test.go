@@ -0,0 +1,81 @@
+package command
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kindctl/internal/retry"
+)
+
+func TestNewCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := New(ctx, "sleep", "5").Run()
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestRun(t *testing.T) {
+	ctx := retry.WithPolicy(context.Background(), retry.Policy{Attempts: 3, Backoff: time.Millisecond})
+
+	calls := 0
+	output, err := Output(ctx, func() *exec.Cmd {
+		calls++
+		if calls < 2 {
+			return New(ctx, "sh", "-c", "echo 'dial tcp 127.0.0.1:6443: connect: connection refused' >&2; exit 1")
+		}
+		return New(ctx, "echo", "ok")
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "ok\n", string(output))
+	assert.Equal(t, 2, calls)
+
+	calls = 0
+	err = Run(ctx, func() *exec.Cmd {
+		calls++
+		return New(ctx, "sh", "-c", "echo 'first line' >&2; echo 'Error: release not found' >&2; exit 1")
+	})
+	assert.EqualError(t, err, "sh: exit status 1: Error: release not found")
+	assert.Equal(t, 1, calls)
+}
+
+func TestRedact(t *testing.T) {
+	args := []string{"docker", "run", "-e", "REGISTRY_PROXY_PASSWORD=s3cr,et", "-e", "REGISTRY_PROXY_USERNAME=me",
+		"--password", "hunter2", "--api-token=abc", "--name", "cache", "registry:2"}
+	assert.Equal(t, []string{"docker", "run", "-e", "REGISTRY_PROXY_PASSWORD=***", "-e", "REGISTRY_PROXY_USERNAME=me",
+		"--password", "***", "--api-token=***", "--name", "cache", "registry:2"}, Redact(args))
+	assert.Equal(t, "docker run -e REGISTRY_PROXY_PASSWORD", String(exec.Command("docker", "run", "-e", "REGISTRY_PROXY_PASSWORD")))
+}
+
+func TestIsSecret(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret bool
+	}{
+		{"REGISTRY_PROXY_PASSWORD", true},
+		{"--password", true},
+		{"PASSWD", true},
+		{"GITHUB_TOKEN", true},
+		{"--api-token", true},
+		{"DB_SECRET", true},
+		{"API_KEY", true},
+		{"--api-key", true},
+		{"aws.secret.key", true},
+		{"NPM_APIKEY", true},
+		{"GOOGLE_APPLICATION_CREDENTIALS", true},
+		{"BYPASS_CACHE", false},
+		{"PASSTHROUGH", false},
+		{"MONKEY", false},
+		{"KEYBOARD_LAYOUT", false},
+		{"TOKENIZER", false},
+		{"REGISTRY_PROXY_USERNAME", false},
+		{"--name", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.secret, isSecret(tt.name), tt.name)
+	}
+}
@@ -0,0 +1,399 @@
+// Package compose converts docker-compose files into kindctl configuration.
+package compose
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"kindctl/internal/config"
+)
+
+// File is the part of a docker-compose file kindctl understands.
+type File struct {
+	Services map[string]Service `yaml:"services"`
+	// unset are the variables referenced without a value or default.
+	unset map[string]bool
+}
+
+// Service is a docker-compose service.
+type Service struct {
+	Image       string      `yaml:"image"`
+	Build       Build       `yaml:"build"`
+	Environment Environment `yaml:"environment"`
+	Ports       []Port      `yaml:"ports"`
+	Expose      []Port      `yaml:"expose"`
+	Command     Command     `yaml:"command"`
+	Volumes     []yaml.Node `yaml:"volumes"`
+}
+
+// Build is the build section, given either as a context path or a mapping.
+type Build struct {
+	Context    string            `yaml:"context"`
+	Dockerfile string            `yaml:"dockerfile"`
+	Args       map[string]string `yaml:"args"`
+}
+
+// UnmarshalYAML accepts the short form "build: ./dir".
+func (b *Build) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		b.Context = node.Value
+		return nil
+	}
+	type plain Build
+	return node.Decode((*plain)(b))
+}
+
+// Environment holds the environment of a service, given either as a mapping
+// or as a list of KEY=VALUE entries.
+type Environment map[string]string
+
+// UnmarshalYAML accepts both forms of the environment section.
+func (e *Environment) UnmarshalYAML(node *yaml.Node) error {
+	*e = Environment{}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			(*e)[node.Content[i].Value] = node.Content[i+1].Value
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			key, value, _ := strings.Cut(item.Value, "=")
+			(*e)[key] = value
+		}
+	default:
+		return fmt.Errorf("line %d: environment must be a mapping or a list", node.Line)
+	}
+	return nil
+}
+
+// Port is the container side of a port mapping.
+type Port int
+
+// UnmarshalYAML accepts the short syntax ("8080:80", "127.0.0.1:8080:80/tcp")
+// and the long syntax with a target key.
+func (p *Port) UnmarshalYAML(node *yaml.Node) error {
+	value := node.Value
+	if node.Kind == yaml.MappingNode {
+		target := lookup(node, "target")
+		if target == nil {
+			return fmt.Errorf("line %d: port without target", node.Line)
+		}
+		value = target.Value
+	}
+	value = strings.SplitN(value, "/", 2)[0]
+	parts := strings.Split(value, ":")
+	value = parts[len(parts)-1]
+	// Ranges map to their first port.
+	value = strings.SplitN(value, "-", 2)[0]
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("line %d: invalid port %q", node.Line, node.Value)
+	}
+	*p = Port(n)
+	return nil
+}
+
+// Command is a service command, given either as a string or a list.
+type Command []string
+
+// UnmarshalYAML accepts both forms of the command.
+func (c *Command) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*c = strings.Fields(node.Value)
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+// Parse reads a docker-compose file, substituting variable references such
+// as ${VAR} and ${VAR:-default} in its values with lookup.
+func Parse(data []byte, lookup Lookup) (*File, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	f := File{unset: map[string]bool{}}
+	if err := interpolateNode(&root, lookup, f.unset); err != nil {
+		return nil, err
+	}
+	if len(root.Content) > 0 {
+		if err := root.Decode(&f); err != nil {
+			return nil, err
+		}
+	}
+	if len(f.Services) == 0 {
+		return nil, fmt.Errorf("no services found")
+	}
+	return &f, nil
+}
+
+// Result is the configuration derived from a compose file.
+type Result struct {
+	// Settings are dotted configuration keys with their values.
+	Settings map[string]string
+	// Custom are the tools generated for unknown images.
+	Custom []config.CustomTool
+	// Apps are the services built from source.
+	Apps []config.App
+	// Manifests maps file names below the manifests directory to their
+	// contents.
+	Manifests map[string][]byte
+	// Warnings describe parts of the compose file that were not converted.
+	Warnings []string
+}
+
+// Convert maps the services of a compose file onto kindctl configuration.
+// Known images enable the matching built-in tool, services with a build
+// section become apps and anything else becomes a custom tool whose
+// manifests are written to manifestsDir.
+func Convert(f *File, manifestsDir string) *Result {
+	r := &Result{Settings: map[string]string{}, Manifests: map[string][]byte{}}
+	names := make([]string, 0, len(f.Services))
+	for name := range f.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	unset := make([]string, 0, len(f.unset))
+	for name := range f.unset {
+		unset = append(unset, name)
+	}
+	sort.Strings(unset)
+	for _, name := range unset {
+		r.warnf("variable %s is not set, using an empty value", name)
+	}
+
+	for _, name := range names {
+		svc := f.Services[name]
+		if len(svc.Volumes) > 0 {
+			r.warnf("%s: volumes are not converted, data is not persisted", name)
+		}
+		switch {
+		case svc.Build.Context != "":
+			r.addApp(name, svc)
+		case svc.Image == "":
+			r.warnf("%s: neither image nor build given, skipped", name)
+		default:
+			if tool := builtin(svc.Image); tool != "" {
+				r.addTool(name, tool, svc)
+			} else {
+				r.addCustom(name, svc, manifestsDir)
+			}
+		}
+	}
+	return r
+}
+
+func (r *Result) warnf(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// builtins maps image repositories to the built-in tool they provide.
+var builtins = map[string]string{
+	"postgres":           "postgres",
+	"bitnami/postgresql": "postgres",
+	"redis":              "redis",
+	"bitnami/redis":      "redis",
+	"rabbitmq":           "rabbitmq",
+	"bitnami/rabbitmq":   "rabbitmq",
+	"axllent/mailpit":    "mailpit",
+	"adminer":            "adminer",
+	"dpage/pgadmin4":     "pgadmin",
+}
+
+// builtin returns the built-in tool serving image, or "".
+func builtin(image string) string {
+	repo, _ := splitImage(image)
+	return builtins[repo]
+}
+
+// splitImage returns the repository of an image without the Docker Hub
+// registry prefix, and its tag.
+func splitImage(image string) (string, string) {
+	image = strings.SplitN(image, "@", 2)[0]
+	repo, tag := image, ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		repo, tag = image[:i], image[i+1:]
+	}
+	repo = strings.TrimPrefix(repo, "docker.io/")
+	repo = strings.TrimPrefix(repo, "library/")
+	return repo, tag
+}
+
+// credentials maps environment variables of the official images onto the
+// settings of the built-in tools.
+var credentials = map[string]map[string]string{
+	"postgres": {
+		"POSTGRES_USER":     "username",
+		"POSTGRES_PASSWORD": "password",
+		"POSTGRES_DB":       "database",
+	},
+	"rabbitmq": {
+		"RABBITMQ_DEFAULT_USER": "username",
+		"RABBITMQ_DEFAULT_PASS": "password",
+	},
+	"pgadmin": {
+		"PGADMIN_DEFAULT_EMAIL":    "email",
+		"PGADMIN_DEFAULT_PASSWORD": "password",
+	},
+}
+
+func (r *Result) addTool(name, tool string, svc Service) {
+	if _, ok := r.Settings[tool+".enabled"]; ok {
+		r.warnf("%s: %s is already provided by another service, skipped", name, tool)
+		return
+	}
+	r.Settings[tool+".enabled"] = "true"
+	r.Settings[tool+".ingress"] = tool + ".local"
+	for env, key := range credentials[tool] {
+		if value, ok := svc.Environment[env]; ok {
+			r.Settings[tool+"."+key] = value
+		}
+	}
+	switch tool {
+	case "postgres":
+		if _, tag := splitImage(svc.Image); tag != "" && tag != "latest" {
+			r.Settings["postgres.version"] = strings.SplitN(tag, "-", 2)[0]
+		}
+	case "mailpit":
+		if user, pass, ok := strings.Cut(svc.Environment["MP_UI_AUTH"], ":"); ok {
+			r.Settings["mailpit.username"] = user
+			r.Settings["mailpit.password"] = pass
+		}
+	}
+}
+
+func (r *Result) addApp(name string, svc Service) {
+	app := config.App{
+		Name:       name,
+		Context:    svc.Build.Context,
+		Dockerfile: svc.Build.Dockerfile,
+		BuildArgs:  svc.Build.Args,
+		Env:        svc.Environment,
+	}
+	if port := containerPort(svc); port != 0 {
+		app.Port = port
+		app.Ingress = name + ".local"
+	}
+	if len(app.Env) == 0 {
+		app.Env = nil
+	}
+	if len(svc.Command) > 0 {
+		r.warnf("%s: command is not converted, the image's default is used", name)
+	}
+	r.Apps = append(r.Apps, app)
+}
+
+func (r *Result) addCustom(name string, svc Service, manifestsDir string) {
+	// The objects keep the service name, which other services connect to,
+	// but the tool must not take the name of a built-in one.
+	toolName := name
+	for _, builtin := range config.ToolNames() {
+		if name == builtin {
+			toolName = "compose-" + name
+			r.warnf("%s: %s is not the built-in %s tool, added as custom tool %s", name, svc.Image, name, toolName)
+		}
+	}
+	file := toolName + ".yaml"
+	ct := config.CustomTool{
+		Name:      toolName,
+		Enabled:   true,
+		Manifests: path.Join(manifestsDir, file),
+	}
+	port := containerPort(svc)
+	if port != 0 {
+		ct.Ingress = config.CustomIngress{Host: name + ".local"}
+	}
+	r.Manifests[file] = manifest(name, svc, port)
+	r.Custom = append(r.Custom, ct)
+}
+
+// containerPort returns the first published or exposed port of a service.
+func containerPort(svc Service) int {
+	if len(svc.Ports) > 0 {
+		return int(svc.Ports[0])
+	}
+	if len(svc.Expose) > 0 {
+		return int(svc.Expose[0])
+	}
+	return 0
+}
+
+// manifest renders a Deployment running the service's image and, when it
+// listens on a port, a Service forwarding port 80 to it.
+func manifest(name string, svc Service, port int) []byte {
+	var b strings.Builder
+	b.WriteString(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: ` + name + `
+  namespace: default
+spec:
+  selector:
+    matchLabels:
+      app: ` + name + `
+  template:
+    metadata:
+      labels:
+        app: ` + name + `
+    spec:
+      containers:
+      - name: ` + name + `
+        image: ` + svc.Image + `
+`)
+	if len(svc.Command) > 0 {
+		b.WriteString("        args:\n")
+		for _, arg := range svc.Command {
+			b.WriteString("        - " + strconv.Quote(arg) + "\n")
+		}
+	}
+	if port != 0 {
+		b.WriteString("        ports:\n        - containerPort: " + strconv.Itoa(port) + "\n")
+	}
+	if len(svc.Environment) > 0 {
+		keys := make([]string, 0, len(svc.Environment))
+		for key := range svc.Environment {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		b.WriteString("        env:\n")
+		for _, key := range keys {
+			b.WriteString("        - name: " + key + "\n          value: " + strconv.Quote(svc.Environment[key]) + "\n")
+		}
+	}
+	if port != 0 {
+		b.WriteString(`---
+apiVersion: v1
+kind: Service
+metadata:
+  name: ` + name + `
+  namespace: default
+spec:
+  selector:
+    app: ` + name + `
+  ports:
+  - port: 80
+    targetPort: ` + strconv.Itoa(port) + `
+`)
+	}
+	return []byte(b.String())
+}
+
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
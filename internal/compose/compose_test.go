@@ -0,0 +1,150 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"kindctl/internal/config"
+)
+
+func TestConvert(t *testing.T) {
+	f, err := Parse([]byte(`
+services:
+  db:
+    image: postgres:15-alpine
+    environment:
+      POSTGRES_USER: app
+      POSTGRES_PASSWORD: secret
+    volumes:
+      - pg:/var/lib/postgresql/data
+  mq:
+    image: docker.io/library/rabbitmq:3-management
+    environment:
+      - RABBITMQ_DEFAULT_USER=mq
+      - RABBITMQ_DEFAULT_PASS=mqpass
+  search:
+    image: elasticsearch:8.13.0
+    command: ["--verbose"]
+    ports:
+      - "127.0.0.1:9201:9200/tcp"
+  worker:
+    image: example/worker
+  api:
+    build:
+      context: ./api
+      dockerfile: Dockerfile.dev
+    ports:
+      - target: 3000
+        published: 8080
+`), noEnv)
+	assert.NoError(t, err)
+	r := Convert(f, "manifests")
+
+	assert.Equal(t, map[string]string{
+		"postgres.enabled":  "true",
+		"postgres.ingress":  "postgres.local",
+		"postgres.username": "app",
+		"postgres.password": "secret",
+		"postgres.version":  "15",
+		"rabbitmq.enabled":  "true",
+		"rabbitmq.ingress":  "rabbitmq.local",
+		"rabbitmq.username": "mq",
+		"rabbitmq.password": "mqpass",
+	}, r.Settings)
+
+	assert.Len(t, r.Apps, 1)
+	assert.Equal(t, "./api", r.Apps[0].Context)
+	assert.Equal(t, "Dockerfile.dev", r.Apps[0].Dockerfile)
+	assert.Equal(t, 3000, r.Apps[0].Port)
+
+	assert.Len(t, r.Custom, 2)
+	assert.Equal(t, "manifests/search.yaml", r.Custom[0].Manifests)
+	assert.Equal(t, "search.local", r.Custom[0].Ingress.Host)
+	assert.Empty(t, r.Custom[1].Ingress.Host)
+	assert.Contains(t, string(r.Manifests["search.yaml"]), "targetPort: 9200")
+	assert.Contains(t, string(r.Manifests["search.yaml"]), `- "--verbose"`)
+	assert.NotContains(t, string(r.Manifests["worker.yaml"]), "kind: Service")
+
+	assert.Len(t, r.Warnings, 1)
+}
+
+func noEnv(string) (string, bool) { return "", false }
+
+func TestInterpolate(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte(`# database
+DB_USER=app
+export DB_PASSWORD="from dotenv"
+PG_TAG=16-alpine # comment
+`), 0644))
+	t.Setenv("DB_PASSWORD", "from env")
+	lookup, err := Env(dir)
+	assert.NoError(t, err)
+
+	f, err := Parse([]byte(`
+services:
+  db:
+    image: postgres:${PG_TAG}
+    environment:
+      POSTGRES_USER: $DB_USER
+      POSTGRES_PASSWORD: ${DB_PASSWORD:-secret}
+      POSTGRES_DB: ${DB_NAME:-app}
+      PRICE: $$5 ${CURRENCY}
+    ports:
+      - "${PG_PORT-5432}:5432"
+`), lookup)
+	assert.NoError(t, err)
+	db := f.Services["db"]
+	assert.Equal(t, "postgres:16-alpine", db.Image)
+	assert.Equal(t, Environment{
+		"POSTGRES_USER":     "app",
+		"POSTGRES_PASSWORD": "from env",
+		"POSTGRES_DB":       "app",
+		"PRICE":             "$5 ",
+	}, db.Environment)
+	assert.Equal(t, []Port{5432}, db.Ports)
+
+	r := Convert(f, "manifests")
+	assert.Equal(t, "16", r.Settings["postgres.version"])
+	assert.Equal(t, []string{"variable CURRENCY is not set, using an empty value"}, r.Warnings)
+
+	_, err = Parse([]byte("services:\n  db:\n    image: ${IMAGE:?set IMAGE}\n"), noEnv)
+	assert.ErrorContains(t, err, "variable IMAGE: set IMAGE")
+	_, err = Parse([]byte("services:\n  db:\n    image: ${IMAGE\n"), noEnv)
+	assert.ErrorContains(t, err, "unterminated")
+}
+
+func TestConvertClashingName(t *testing.T) {
+	f, err := Parse([]byte(`
+services:
+  redis:
+    image: redis/redis-stack:7.2.0-v10
+    ports: ["6379:6379"]
+  postgres:
+    image: postgis/postgis:16-3.4
+`), noEnv)
+	assert.NoError(t, err)
+	r := Convert(f, "manifests")
+	assert.Empty(t, r.Settings)
+	assert.Len(t, r.Custom, 2)
+	assert.Equal(t, "compose-postgres", r.Custom[0].Name)
+	assert.Equal(t, "manifests/compose-postgres.yaml", r.Custom[0].Manifests)
+	assert.Equal(t, "compose-redis", r.Custom[1].Name)
+	assert.Equal(t, "redis.local", r.Custom[1].Ingress.Host)
+	// The objects keep the service name other services connect to.
+	assert.Contains(t, string(r.Manifests["compose-redis.yaml"]), "name: redis\n")
+	assert.Len(t, r.Warnings, 2)
+	assert.Contains(t, r.Warnings[0], "added as custom tool compose-postgres")
+
+	cfg := config.DefaultConfig()
+	cfg.Custom = r.Custom
+	data, err := yaml.Marshal(cfg)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), config.FileName)
+	assert.NoError(t, os.WriteFile(path, data, 0644))
+	_, err = config.LoadConfig(path)
+	assert.NoError(t, err)
+}
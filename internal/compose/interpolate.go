@@ -0,0 +1,203 @@
+package compose
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lookup returns the value of a variable and whether it is set.
+type Lookup func(name string) (string, bool)
+
+// Env looks variables up in the process environment, then in the .env file
+// of dir, like docker compose does. A missing .env file is ignored.
+func Env(dir string) (Lookup, error) {
+	dotenv := map[string]string{}
+	data, err := os.ReadFile(filepath.Join(dir, ".env"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if dotenv, err = parseDotenv(data); err != nil {
+			return nil, fmt.Errorf(".env: %w", err)
+		}
+	}
+	return func(name string) (string, bool) {
+		if value, ok := os.LookupEnv(name); ok {
+			return value, true
+		}
+		value, ok := dotenv[name]
+		return value, ok
+	}, nil
+}
+
+// parseDotenv reads KEY=VALUE lines, skipping blank lines and comments.
+// Values may be quoted and keys prefixed with export.
+func parseDotenv(data []byte) (map[string]string, error) {
+	vars := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(strings.TrimPrefix(text, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+// interpolateNode replaces the variables in the scalar values below node.
+// Keys are left alone, as in docker compose.
+func interpolateNode(node *yaml.Node, lookup Lookup, unset map[string]bool) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolateNode(child, lookup, unset); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolateNode(node.Content[i], lookup, unset); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(node.Value, "$") {
+			return nil
+		}
+		value, err := interpolate(node.Value, lookup, unset)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		node.Value = value
+		if node.Style == 0 {
+			// Let the substituted value resolve to a number or boolean.
+			node.Tag = ""
+		}
+	}
+	return nil
+}
+
+// interpolate substitutes $VAR, ${VAR} and ${VAR<op>word} in s, with the
+// operators of the compose specification: :- and - for defaults, :? and ?
+// for required variables and :+ and + for alternatives. $$ is a literal $.
+// Unset variables without a default become empty and are added to unset.
+func interpolate(s string, lookup Lookup, unset map[string]bool) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			out.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			out.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated variable in %q", s)
+			}
+			value, err := expand(s[i+2:i+end], lookup, unset)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i += end
+		case isNameByte(next, true):
+			j := i + 1
+			for j < len(s) && isNameByte(s[j], false) {
+				j++
+			}
+			value, err := expand(s[i+1:j], lookup, unset)
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(value)
+			i = j - 1
+		default:
+			out.WriteByte('$')
+		}
+	}
+	return out.String(), nil
+}
+
+// expand resolves the inside of one ${...} reference.
+func expand(ref string, lookup Lookup, unset map[string]bool) (string, error) {
+	end := 0
+	for end < len(ref) && isNameByte(ref[end], end == 0) {
+		end++
+	}
+	name, rest := ref[:end], ref[end:]
+	if name == "" {
+		return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+	}
+	value, set := lookup(name)
+	op, word := "", ""
+	if rest != "" {
+		op = rest[:1]
+		if strings.HasPrefix(rest, ":") && len(rest) > 1 {
+			op = rest[:2]
+		}
+		word = rest[len(op):]
+	}
+	switch op {
+	case "":
+		if !set {
+			unset[name] = true
+		}
+		return value, nil
+	case ":-":
+		if !set || value == "" {
+			return word, nil
+		}
+		return value, nil
+	case "-":
+		if !set {
+			return word, nil
+		}
+		return value, nil
+	case ":?", "?":
+		if !set || op == ":?" && value == "" {
+			if word == "" {
+				word = "not set"
+			}
+			return "", fmt.Errorf("variable %s: %s", name, word)
+		}
+		return value, nil
+	case ":+":
+		if set && value != "" {
+			return word, nil
+		}
+		return "", nil
+	case "+":
+		if set {
+			return word, nil
+		}
+		return "", nil
+	}
+	return "", fmt.Errorf("invalid variable reference ${%s}", ref)
+}
+
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,45 +16,223 @@ type Config struct {
 	} `yaml:"logging"`
 	Cluster struct {
 		Name string `yaml:"name"`
+		// PreloadImages pulls tool images on the host and loads them into
+		// the nodes before installing.
+		PreloadImages bool `yaml:"preloadImages"`
+		// Mirrors configures containerd on the nodes to pull through
+		// mirrors or local caches.
+		Mirrors []RegistryMirror `yaml:"mirrors,omitempty"`
+		// Ingress publishes the NGINX ingress controller on the host.
+		Ingress struct {
+			// Enabled installs the controller on the control-plane node,
+			// labelled ingress-ready, with its ports mapped to the host.
+			// Unset means true.
+			Enabled *bool `yaml:"enabled,omitempty"`
+			// HTTPPort and HTTPSPort are the host ports, 80 and 443 if unset.
+			HTTPPort  int `yaml:"httpPort,omitempty"`
+			HTTPSPort int `yaml:"httpsPort,omitempty"`
+		} `yaml:"ingress,omitempty"`
 	} `yaml:"cluster"`
 	Postgres struct {
-		Enabled  bool   `yaml:"enabled"`
-		Ingress  string `yaml:"ingress"`
-		Version  string `yaml:"version"`
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
-		Database string `yaml:"database"`
+		Enabled       bool   `yaml:"enabled"`
+		Ingress       string `yaml:"ingress"`
+		Version       string `yaml:"version"`
+		Username      string `yaml:"username"`
+		Password      string `yaml:"password"`
+		Database      string `yaml:"database"`
+		ChartSettings `yaml:",inline"`
+		Workload      `yaml:",inline"`
+		Persistence   Persistence `yaml:"persistence,omitempty"`
 	} `yaml:"postgres"`
 	Redis struct {
 		Enabled bool   `yaml:"enabled"`
 		Ingress string `yaml:"ingress"`
+		// Password enables authentication; empty turns it off.
+		Password      string `yaml:"password,omitempty"`
+		ChartSettings `yaml:",inline"`
+		Workload      `yaml:",inline"`
+		Persistence   Persistence `yaml:"persistence,omitempty"`
 	} `yaml:"redis"`
 	PgAdmin struct {
-		Enabled  bool   `yaml:"enabled"`
-		Ingress  string `yaml:"ingress"`
-		Email    string `yaml:"email"`
-		Password string `yaml:"password"`
+		Enabled       bool   `yaml:"enabled"`
+		Ingress       string `yaml:"ingress"`
+		Email         string `yaml:"email"`
+		Password      string `yaml:"password"`
+		ChartSettings `yaml:",inline"`
+		Workload      `yaml:",inline"`
+		Persistence   Persistence `yaml:"persistence,omitempty"`
 	} `yaml:"pgadmin"`
 	Adminer struct {
-		Enabled bool   `yaml:"enabled"`
-		Ingress string `yaml:"ingress"`
-	} `yaml:"adminer"`
-	RabbitMQ struct {
 		Enabled  bool   `yaml:"enabled"`
 		Ingress  string `yaml:"ingress"`
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
+		Workload `yaml:",inline"`
+	} `yaml:"adminer"`
+	RabbitMQ struct {
+		Enabled       bool   `yaml:"enabled"`
+		Ingress       string `yaml:"ingress"`
+		Username      string `yaml:"username"`
+		Password      string `yaml:"password"`
+		ChartSettings `yaml:",inline"`
+		Workload      `yaml:",inline"`
+		Persistence   Persistence `yaml:"persistence,omitempty"`
 	} `yaml:"rabbitmq"`
 	Mailpit struct {
-		Enabled  bool   `yaml:"enabled"`
-		Ingress  string `yaml:"ingress"`
-		Username string `yaml:"username"`
-		Password string `yaml:"password"`
+		Enabled     bool   `yaml:"enabled"`
+		Ingress     string `yaml:"ingress"`
+		Username    string `yaml:"username"`
+		Password    string `yaml:"password"`
+		Workload    `yaml:",inline"`
+		Persistence Persistence `yaml:"persistence,omitempty"`
 	} `yaml:"mailpit"`
 	Dashboard struct {
 		Enabled bool   `yaml:"enabled"`
 		Ingress string `yaml:"ingress"`
+		// AdminUser creates a cluster-admin ServiceAccount to log in with.
+		AdminUser bool `yaml:"adminUser"`
+		Workload  `yaml:",inline"`
 	} `yaml:"dashboard"`
+	Registry struct {
+		Enabled bool   `yaml:"enabled"`
+		Name    string `yaml:"name"`
+		Port    int    `yaml:"port"`
+	} `yaml:"registry"`
+	// Custom are additional tools installed from manifests.
+	Custom []CustomTool `yaml:"custom,omitempty"`
+	// Apps are the services under development, deployed by "kindctl dev".
+	Apps []App `yaml:"apps,omitempty"`
+	// Profiles holds named partial configurations that are merged on top of
+	// the rest of the file when selected.
+	Profiles map[string]yaml.Node `yaml:"profiles,omitempty"`
+
+	// dir is the directory relative paths in the configuration refer to.
+	dir string
+}
+
+// Path resolves a path from the configuration against the directory of the
+// configuration file.
+func (cfg *Config) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) || cfg.dir == "" {
+		return path
+	}
+	return filepath.Join(cfg.dir, path)
+}
+
+// ChartSettings customizes the Helm chart of a chart-based tool.
+type ChartSettings struct {
+	// ChartVersion pins the chart; empty means the version recorded in
+	// kindctl.lock, or the latest one.
+	ChartVersion string `yaml:"chartVersion,omitempty"`
+	// ValuesFiles and then Values are deep-merged over the values kindctl
+	// generates for the chart.
+	ValuesFiles []string               `yaml:"valuesFiles,omitempty"`
+	Values      map[string]interface{} `yaml:"values,omitempty"`
+}
+
+// Workload sizes the pods of a tool.
+type Workload struct {
+	// Replicas is the number of pods; zero keeps the tool's default of one.
+	Replicas  int       `yaml:"replicas,omitempty"`
+	Resources Resources `yaml:"resources,omitempty"`
+}
+
+// Resources are the requests and limits of a tool's containers.
+type Resources struct {
+	Requests ResourceList `yaml:"requests,omitempty"`
+	Limits   ResourceList `yaml:"limits,omitempty"`
+}
+
+// ResourceList holds Kubernetes quantities such as 250m or 512Mi.
+type ResourceList struct {
+	CPU    string `yaml:"cpu,omitempty"`
+	Memory string `yaml:"memory,omitempty"`
+}
+
+// Persistence controls the PersistentVolumeClaim holding a tool's data.
+type Persistence struct {
+	// Enabled keeps the data in a PersistentVolumeClaim; unset keeps the
+	// tool's default.
+	Enabled      *bool  `yaml:"enabled,omitempty"`
+	Size         string `yaml:"size,omitempty"`
+	StorageClass string `yaml:"storageClass,omitempty"`
+}
+
+// RegistryMirror redirects image pulls for a registry to mirrors.
+type RegistryMirror struct {
+	// Registry is the registry being mirrored, e.g. docker.io or ghcr.io.
+	Registry string `yaml:"registry"`
+	// Endpoints are mirror URLs tried in order before the registry itself.
+	Endpoints []string `yaml:"endpoints,omitempty"`
+	// Username and Password authenticate against the endpoints and the
+	// upstream of a pull-through cache. ${VAR} references are expanded.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// PullThrough starts a local caching proxy for the registry that is
+	// kept across clusters.
+	PullThrough bool `yaml:"pullThrough,omitempty"`
+}
+
+// CustomTool is a tool kindctl does not ship, installed from a Helm chart or
+// from manifests.
+type CustomTool struct {
+	Name    string `yaml:"name"`
+	Enabled bool   `yaml:"enabled"`
+	// DependsOn names tools that are installed first when they are enabled.
+	DependsOn []string `yaml:"dependsOn,omitempty"`
+	// Chart installs the tool as a Helm release named after the tool.
+	Chart CustomChart `yaml:"chart,omitempty"`
+	// Values and the file at ValuesFile are passed to the chart, inline
+	// values taking precedence.
+	Values     map[string]interface{} `yaml:"values,omitempty"`
+	ValuesFile string                 `yaml:"valuesFile,omitempty"`
+	// Namespace of the release, defaulting to default.
+	Namespace string `yaml:"namespace,omitempty"`
+	// Manifests is a file, directory or http(s) URL of manifests to apply.
+	Manifests string        `yaml:"manifests,omitempty"`
+	Ingress   CustomIngress `yaml:"ingress,omitempty"`
+}
+
+// CustomChart references the Helm chart of a custom tool.
+type CustomChart struct {
+	// Repo is the URL of the chart repository. Without it, Name is a local
+	// chart directory or archive, or an oci:// reference.
+	Repo    string `yaml:"repo,omitempty"`
+	Name    string `yaml:"name,omitempty"`
+	Version string `yaml:"version,omitempty"`
+}
+
+// CustomIngress exposes a custom tool's Service on a host name.
+type CustomIngress struct {
+	Host string `yaml:"host,omitempty"`
+	// Service defaults to the tool name and Port to 80.
+	Service string `yaml:"service,omitempty"`
+	Port    int    `yaml:"port,omitempty"`
+}
+
+// App is a service built from source and deployed next to the tools.
+type App struct {
+	Name string `yaml:"name"`
+	// Context is the Docker build context.
+	Context string `yaml:"context,omitempty"`
+	// Dockerfile is relative to the build context.
+	Dockerfile string            `yaml:"dockerfile,omitempty"`
+	BuildArgs  map[string]string `yaml:"buildArgs,omitempty"`
+	// Manifests is a file or directory of manifests to apply. Containers
+	// whose image is the app name run the freshly built image.
+	Manifests string `yaml:"manifests,omitempty"`
+	// Chart is a local Helm chart installed with image.repository and
+	// image.tag set to the built image, plus Values.
+	Chart  string                 `yaml:"chart,omitempty"`
+	Values map[string]interface{} `yaml:"values,omitempty"`
+	// Port is the container port of the generated Deployment and Service,
+	// used when neither Manifests nor Chart is set.
+	Port    int    `yaml:"port,omitempty"`
+	Ingress string `yaml:"ingress,omitempty"`
+	// Env is added to the app's containers, or passed to its chart as the
+	// env value. Values are templates with the connection details of the
+	// enabled tools, e.g. {{ .postgres.url }}.
+	Env map[string]string `yaml:"env,omitempty"`
+	// Watch lists the paths that trigger a rebuild, defaulting to Context.
+	Watch []string `yaml:"watch,omitempty"`
 }
 
 // LoadConfig reads and parses the YAML configuration file.
@@ -65,31 +246,78 @@ func LoadConfig(filePath string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	cfg.dir = filepath.Dir(filePath)
+	cfg.applyDefaults()
 
 	return &cfg, nil
 }
 
 // DefaultConfig returns a default configuration for initialization.
 func DefaultConfig() *Config {
-	return &Config{
-		Logging: struct {
-			Level string `yaml:"level"`
-		}{
-			Level: "info",
-		},
-		Cluster: struct {
-			Name string `yaml:"name"`
-		}{
-			Name: "kind-cluster",
-		},
-		Dashboard: struct {
-			Enabled bool   `yaml:"enabled"`
-			Ingress string `yaml:"ingress"`
-		}{
-			Enabled: true,
-			Ingress: "dashboard.local",
-		},
+	cfg := &Config{}
+	cfg.Logging.Level = "info"
+	cfg.Cluster.Name = "kind-cluster"
+	cfg.Dashboard.Enabled = true
+	cfg.Dashboard.Ingress = "dashboard.local"
+	cfg.applyDefaults()
+	return cfg
+}
+
+// applyDefaults fills in settings that must not be left empty.
+func (cfg *Config) applyDefaults() {
+	if cfg.Registry.Name == "" {
+		cfg.Registry.Name = "kind-registry"
+	}
+	if cfg.Registry.Port == 0 {
+		cfg.Registry.Port = 5001
+	}
+}
+
+// IngressEnabled reports whether the cluster runs the NGINX ingress
+// controller.
+func (cfg *Config) IngressEnabled() bool {
+	return cfg.Cluster.Ingress.Enabled == nil || *cfg.Cluster.Ingress.Enabled
+}
+
+// validate rejects settings the tools cannot run with.
+func (cfg *Config) validate() error {
+	// Mailpit keeps its mailbox in a SQLite database on a ReadWriteOnce
+	// claim, which a second pod can neither mount nor share safely.
+	if cfg.Mailpit.Replicas > 1 {
+		return fmt.Errorf("mailpit.replicas: mailpit runs a single replica, got %d", cfg.Mailpit.Replicas)
+	}
+	names := map[string]bool{}
+	for _, name := range ToolNames() {
+		names[name] = true
+	}
+	for _, ct := range cfg.Custom {
+		if ct.Name == "" {
+			continue
+		}
+		if names[ct.Name] {
+			return fmt.Errorf("custom tool %q clashes with another tool", ct.Name)
+		}
+		names[ct.Name] = true
+	}
+	return nil
+}
+
+// JSON renders the configuration as JSON with the same keys as the YAML
+// file. Profiles are left out as they are already applied.
+func (cfg *Config) JSON() ([]byte, error) {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, err
 	}
+	delete(values, "profiles")
+	return json.MarshalIndent(values, "", "  ")
 }
 
 // SaveConfig writes the configuration to a file.
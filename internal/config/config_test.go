@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -48,6 +49,29 @@ postgres:
 	assert.Equal(t, "testdb", cfg.Postgres.Database)
 }
 
+func TestLoadToolChartSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	assert.NoError(t, os.WriteFile(path, []byte(`postgres:
+  enabled: true
+  chartVersion: 15.5.1
+  valuesFiles: [postgres-values.yaml]
+  values:
+    metrics:
+      enabled: true
+redis:
+  chartVersion: 19.0.2
+`), 0644))
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "15.5.1", cfg.Postgres.ChartVersion)
+	assert.Equal(t, []string{"postgres-values.yaml"}, cfg.Postgres.ValuesFiles)
+	assert.Equal(t, map[string]interface{}{"metrics": map[string]interface{}{"enabled": true}}, cfg.Postgres.Values)
+	assert.Equal(t, "19.0.2", cfg.Redis.ChartVersion)
+	assert.Empty(t, cfg.Redis.ValuesFiles)
+	assert.Nil(t, cfg.Redis.Values)
+}
+
 func TestDefaultConfig(t *testing.T) {
 	cfg := DefaultConfig()
 	assert.Equal(t, "info", cfg.Logging.Level)
@@ -56,3 +80,190 @@ func TestDefaultConfig(t *testing.T) {
 	assert.Equal(t, "dashboard.local", cfg.Dashboard.Ingress)
 	assert.False(t, cfg.Postgres.Enabled)
 }
+
+func TestLoadLayered(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "kindctl.yaml")
+	extra := filepath.Join(dir, "extra.yaml")
+	local := filepath.Join(dir, LocalFileName)
+	assert.NoError(t, os.WriteFile(base, []byte(`
+cluster:
+  name: shared
+postgres:
+  enabled: true
+  ingress: postgres.local
+  version: "15"
+`), 0644))
+	assert.NoError(t, os.WriteFile(extra, []byte(`
+redis:
+  enabled: true
+postgres:
+  version: "16"
+`), 0644))
+	assert.NoError(t, os.WriteFile(local, []byte(`
+postgres:
+  ingress: pg.me.local
+`), 0644))
+
+	layered, err := Load(Options{Files: []string{base, extra}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{base, extra, local}, layered.Files)
+
+	cfg := layered.Config
+	assert.Equal(t, "shared", cfg.Cluster.Name)
+	assert.True(t, cfg.Postgres.Enabled)
+	assert.Equal(t, "16", cfg.Postgres.Version)
+	assert.Equal(t, "pg.me.local", cfg.Postgres.Ingress)
+	assert.True(t, cfg.Redis.Enabled)
+
+	origin, ok := layered.Origin("postgres.version")
+	assert.True(t, ok)
+	assert.Equal(t, extra, origin)
+	origin, _ = layered.Origin("postgres.ingress")
+	assert.Equal(t, local, origin)
+	origin, _ = layered.Origin("cluster.name")
+	assert.Equal(t, base, origin)
+
+	view, err := layered.View()
+	assert.NoError(t, err)
+	assert.Contains(t, string(view), "ingress: pg.me.local # from "+local)
+}
+
+func TestLoadProfiles(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "kindctl.yaml")
+	assert.NoError(t, os.WriteFile(base, []byte(`
+postgres:
+  enabled: true
+  version: "15"
+profiles:
+  frontend:
+    postgres:
+      enabled: false
+    mailpit:
+      enabled: true
+  e2e:
+    postgres:
+      version: "16"
+`), 0644))
+
+	layered, err := Load(Options{Files: []string{base}, Profiles: []string{"frontend", "e2e"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"frontend", "e2e"}, layered.Profiles)
+	assert.False(t, layered.Config.Postgres.Enabled)
+	assert.True(t, layered.Config.Mailpit.Enabled)
+	assert.Equal(t, "16", layered.Config.Postgres.Version)
+	origin, _ := layered.Origin("postgres.version")
+	assert.Equal(t, "profile e2e", origin)
+
+	_, err = Load(Options{Files: []string{base}, Profiles: []string{"backend"}})
+	assert.ErrorContains(t, err, "unknown profile")
+}
+
+func TestDiscover(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "repo", "services", "api")
+	assert.NoError(t, os.MkdirAll(nested, 0755))
+	assert.NoError(t, os.Mkdir(filepath.Join(root, "repo", ".git"), 0755))
+
+	// A config above the repository root is not picked up.
+	assert.NoError(t, os.WriteFile(filepath.Join(root, FileName), nil, 0644))
+	_, err := Discover(nested)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	want := filepath.Join(root, "repo", FileName)
+	assert.NoError(t, os.WriteFile(want, nil, 0644))
+	found, err := Discover(nested)
+	assert.NoError(t, err)
+	assert.Equal(t, want, found)
+}
+
+func TestDocumentSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	assert.NoError(t, os.WriteFile(path, []byte(`# shared settings
+postgres:
+    enabled: true
+    # keep in sync with production
+    version: "15"
+`), 0644))
+
+	doc, err := OpenDocument(path)
+	assert.NoError(t, err)
+	assert.NoError(t, doc.Set("postgres.version", "16"))
+	assert.NoError(t, doc.Set("redis.enabled", "true"))
+	assert.NoError(t, doc.Set("postgres.chartVersion", "15.5.1"))
+	assert.NoError(t, doc.Set("postgres.values.metrics.enabled", "true"))
+	assert.ErrorContains(t, doc.Set("postgres.port", "5432"), "unknown setting")
+	assert.Error(t, doc.Set("redis.enabled", "maybe"))
+	assert.NoError(t, doc.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `# shared settings
+postgres:
+    enabled: true
+    # keep in sync with production
+    version: "16"
+    chartVersion: 15.5.1
+    values:
+        metrics:
+            enabled: true
+redis:
+    enabled: true
+`, string(data))
+}
+
+func TestDocumentSetEmptyKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	assert.NoError(t, os.WriteFile(path, []byte(`redis: # filled in by config set
+rabbitmq:
+`), 0644))
+
+	doc, err := OpenDocument(path)
+	assert.NoError(t, err)
+	assert.NoError(t, doc.Set("redis.password", "s3cret"))
+	assert.Error(t, doc.Set("rabbitmq.enabled", "maybe"))
+	assert.NoError(t, doc.Save())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, `redis: # filled in by config set
+  password: s3cret
+rabbitmq:
+`, string(data))
+}
+
+func TestDocumentSetWorkload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	doc, err := OpenDocument(path)
+	assert.NoError(t, err)
+	assert.NoError(t, doc.Set("mailpit.replicas", "1"))
+	assert.NoError(t, doc.Set("postgres.resources.limits.memory", "512Mi"))
+	assert.NoError(t, doc.Set("postgres.persistence.enabled", "false"))
+	assert.Error(t, doc.Set("postgres.replicas", "two"))
+	assert.ErrorContains(t, doc.Set("mailpit.replicas", "2"), "single replica")
+	assert.ErrorContains(t, doc.Set("adminer.persistence.size", "1Gi"), "unknown setting")
+	assert.NoError(t, doc.Save())
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, cfg.Mailpit.Replicas)
+	assert.Equal(t, "512Mi", cfg.Postgres.Resources.Limits.Memory)
+	assert.False(t, *cfg.Postgres.Persistence.Enabled)
+}
+
+func TestDocumentAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	doc, err := OpenDocument(path)
+	assert.NoError(t, err)
+	assert.NoError(t, doc.Append("custom", CustomTool{Name: "search", Enabled: true, Manifests: "manifests/search.yaml"}))
+	assert.NoError(t, doc.Append("custom", CustomTool{Name: "search", Manifests: "search"}))
+	assert.ErrorContains(t, doc.Append("postgres", CustomTool{Name: "search"}), "not a list")
+	assert.ErrorContains(t, doc.Append("custom", CustomTool{Name: "redis", Manifests: "redis.yaml"}), "clashes with another tool")
+	assert.NoError(t, doc.Save())
+
+	cfg, err := LoadConfig(path)
+	assert.NoError(t, err)
+	assert.Len(t, cfg.Custom, 1)
+	assert.Equal(t, filepath.Join(filepath.Dir(path), "search"), cfg.Path(cfg.Custom[0].Manifests))
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is the name of the shared configuration file.
+const FileName = "kindctl.yaml"
+
+// EnvConfig names the environment variable that points at the configuration
+// file, bypassing discovery.
+const EnvConfig = "KINDCTL_CONFIG"
+
+// ErrNotFound is returned by Discover when no configuration file exists in
+// the searched directories.
+var ErrNotFound = errors.New("configuration file not found")
+
+// vcsMarkers are entries that mark the root of a repository.
+var vcsMarkers = []string{".git", ".hg", ".svn"}
+
+// Discover looks for kindctl.yaml in dir and its parents, stopping at the
+// root of the enclosing repository or of the filesystem.
+func Discover(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	start := dir
+	for {
+		candidate := filepath.Join(dir, FileName)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+		if isVCSRoot(dir) {
+			return "", fmt.Errorf("%w: no %s between %s and repository root %s", ErrNotFound, FileName, start, dir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("%w: no %s in %s or any parent directory", ErrNotFound, FileName, start)
+		}
+		dir = parent
+	}
+}
+
+func isVCSRoot(dir string) bool {
+	for _, marker := range vcsMarkers {
+		if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,277 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document is a configuration file opened for editing. Changes are made to
+// the YAML node tree so comments and key order survive a round trip.
+type Document struct {
+	path   string
+	doc    yaml.Node
+	indent int
+}
+
+// OpenDocument reads a configuration file for editing. A missing file yields
+// an empty document that is created on Save.
+func OpenDocument(path string) (*Document, error) {
+	d := &Document{path: path, indent: 2}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, &d.doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(d.doc.Content) == 0 {
+		d.doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if d.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: top-level value must be a mapping", path)
+	}
+	d.indent = detectIndent(data)
+	return d, nil
+}
+
+// Path returns the file the document was read from.
+func (d *Document) Path() string {
+	return d.path
+}
+
+// Set assigns value to the dotted key path, creating intermediate mappings
+// as needed. The value is parsed as YAML; values for string settings are
+// quoted when they would otherwise be read as another type.
+func (d *Document) Set(path, value string) error {
+	keys := splitPath(path)
+	kind, ok := fieldKind(keys)
+	if !ok {
+		return fmt.Errorf("unknown setting %q", path)
+	}
+	node := d.doc.Content[0]
+	// grown is the first mapping that keys were added to, and its length
+	// before, so that undo leaves no empty keys behind when a value is
+	// rejected.
+	var grown *yaml.Node
+	grownLen := 0
+	// nulled is the mapping whose null value at nulledIndex, e.g. of an
+	// empty "redis:", was replaced by a mapping on the way.
+	var nulled, nulledValue *yaml.Node
+	nulledIndex := 0
+	undo := func() {
+		if grown != nil {
+			grown.Content = grown.Content[:grownLen]
+		}
+		if nulled != nil {
+			nulled.Content[nulledIndex] = nulledValue
+		}
+	}
+	for i, key := range keys {
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("cannot set %s: %s is not a mapping", path, strings.Join(keys[:i], "."))
+		}
+		j := mappingIndex(node, key)
+		if j < 0 {
+			if grown == nil {
+				grown, grownLen = node, len(node.Content)
+			}
+			child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+			j = len(node.Content) - 2
+		}
+		if i < len(keys)-1 {
+			if child := node.Content[j+1]; child.Kind == yaml.ScalarNode && child.Tag == "!!null" {
+				if nulled == nil {
+					nulled, nulledIndex, nulledValue = node, j+1, child
+				}
+				node.Content[j+1] = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map",
+					HeadComment: child.HeadComment, LineComment: child.LineComment, FootComment: child.FootComment}
+			}
+			node = node.Content[j+1]
+			continue
+		}
+		parsed, err := parseValue(value, kind)
+		if err != nil {
+			undo()
+			return fmt.Errorf("invalid value for %s: %w", path, err)
+		}
+		old := node.Content[j+1]
+		parsed.HeadComment, parsed.LineComment, parsed.FootComment = old.HeadComment, old.LineComment, old.FootComment
+		if parsed.Kind == yaml.ScalarNode && old.Kind == yaml.ScalarNode && parsed.Style == 0 {
+			parsed.Style = old.Style
+		}
+		node.Content[j+1] = parsed
+		if err := d.validate(); err != nil {
+			node.Content[j+1] = old
+			undo()
+			return err
+		}
+	}
+	return nil
+}
+
+// Append adds value to the list at the top-level key, creating the list if
+// needed. An entry whose name matches the new one is replaced.
+func (d *Document) Append(key string, value interface{}) error {
+	kind, ok := fieldKind([]string{key})
+	if !ok || kind != reflect.Slice {
+		return fmt.Errorf("%s is not a list setting", key)
+	}
+	var item yaml.Node
+	if err := item.Encode(value); err != nil {
+		return err
+	}
+	root := d.doc.Content[0]
+	i := mappingIndex(root, key)
+	if i < 0 {
+		root.Content = append(root.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key},
+			&yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"})
+		i = len(root.Content) - 2
+	}
+	list := root.Content[i+1]
+	if list.Kind == yaml.ScalarNode && list.Tag == "!!null" {
+		*list = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", HeadComment: list.HeadComment, LineComment: list.LineComment}
+	}
+	if list.Kind != yaml.SequenceNode {
+		return fmt.Errorf("cannot append to %s: not a list", key)
+	}
+	old := list.Content
+	name := lookup(&item, []string{"name"})
+	replaced := false
+	if name != nil {
+		for j, entry := range list.Content {
+			if n := lookup(entry, []string{"name"}); n != nil && n.Value == name.Value {
+				list.Content = append(append(append([]*yaml.Node{}, old[:j]...), &item), old[j+1:]...)
+				replaced = true
+				break
+			}
+		}
+	}
+	if !replaced {
+		list.Content = append(append([]*yaml.Node{}, old...), &item)
+	}
+	if err := d.validate(); err != nil {
+		list.Content = old
+		return err
+	}
+	return nil
+}
+
+// Save writes the document back to its file.
+func (d *Document) Save() error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(d.indent)
+	if err := enc.Encode(&d.doc); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(d.path, buf.Bytes(), 0644)
+}
+
+// validate rejects documents whose values do not fit the settings' types.
+func (d *Document) validate() error {
+	var cfg Config
+	if err := d.doc.Decode(&cfg); err != nil {
+		return fmt.Errorf("%s: %w", d.path, err)
+	}
+	return cfg.validate()
+}
+
+// parseValue turns a command-line value into a YAML node.
+func parseValue(value string, kind reflect.Kind) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle}, nil
+	}
+	node := doc.Content[0]
+	if kind == reflect.String && (node.Kind != yaml.ScalarNode || node.Tag != "!!str") {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle}, nil
+	}
+	return node, nil
+}
+
+// fieldKind returns the kind of the Config field addressed by the YAML keys
+// and whether the path is a known setting. Keys below a free-form mapping
+// such as profiles are accepted with kind reflect.Invalid.
+func fieldKind(keys []string) (reflect.Kind, bool) {
+	t := reflect.TypeOf(Config{})
+	for _, key := range keys {
+		if t.Kind() == reflect.Map {
+			return reflect.Invalid, true
+		}
+		if t.Kind() != reflect.Struct {
+			return reflect.Invalid, false
+		}
+		field, ok := fieldByTag(t, key)
+		if !ok {
+			return reflect.Invalid, false
+		}
+		t = field.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	return t.Kind(), true
+}
+
+// fieldByTag returns the field of struct type t whose YAML key is key,
+// looking into embedded structs inlined with ",inline".
+func fieldByTag(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if field.Anonymous && name == "" && options == "inline" && field.Type.Kind() == reflect.Struct {
+			if inner, ok := fieldByTag(field.Type, key); ok {
+				return inner, true
+			}
+			continue
+		}
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// ToolNames returns the configuration sections of the tools, recognised by
+// their enabled and ingress settings.
+func ToolNames() []string {
+	var names []string
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Type.Kind() != reflect.Struct {
+			continue
+		}
+		_, enabled := fieldByTag(field.Type, "enabled")
+		_, ingress := fieldByTag(field.Type, "ingress")
+		if enabled && ingress {
+			names = append(names, strings.Split(field.Tag.Get("yaml"), ",")[0])
+		}
+	}
+	return names
+}
+
+// detectIndent returns the indentation width used by a YAML file, defaulting
+// to two spaces.
+func detectIndent(data []byte) int {
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimLeft(line, " ")
+		if indent := len(line) - len(trimmed); indent > 0 && trimmed != "" && !strings.HasPrefix(trimmed, "#") && !strings.HasPrefix(trimmed, "-") {
+			return indent
+		}
+	}
+	return 2
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/kindctl.yaml.tmpl
+var templates embed.FS
+
+// Templates are named sets of tools offered by "kindctl init --template".
+var Templates = map[string][]string{
+	"minimal":     {"dashboard"},
+	"web-backend": {"dashboard", "postgres", "redis", "adminer", "mailpit"},
+	"messaging":   {"dashboard", "rabbitmq", "mailpit"},
+	"full":        ToolNames(),
+}
+
+// TemplateNames returns the names of the available templates in sorted order.
+func TemplateNames() []string {
+	names := make([]string, 0, len(Templates))
+	for name := range Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Generate renders a fully commented configuration file containing every
+// tool section, with the given tools enabled.
+func Generate(clusterName string, tools []string) ([]byte, error) {
+	enabled := map[string]bool{}
+	for _, tool := range tools {
+		if !contains(ToolNames(), tool) {
+			return nil, fmt.Errorf("unknown tool %q (available: %s)", tool, strings.Join(ToolNames(), ", "))
+		}
+		enabled[tool] = true
+	}
+	tmpl, err := template.ParseFS(templates, "templates/kindctl.yaml.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	data := templateData{ClusterName: clusterName, enabled: enabled}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// templateData is passed to the configuration file template.
+type templateData struct {
+	ClusterName string
+	enabled     map[string]bool
+}
+
+// Enabled reports whether the tool was selected.
+func (d templateData) Enabled(tool string) bool {
+	return d.enabled[tool]
+}
@@ -0,0 +1,287 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LocalFileName is the personal override file that is merged on top of the
+// shared configuration when it sits next to it. It is meant to be gitignored.
+const LocalFileName = "kindctl.local.yaml"
+
+// EnvProfile names the environment variable holding the comma-separated
+// profiles to apply when none are given on the command line.
+const EnvProfile = "KINDCTL_PROFILE"
+
+// Layered is the effective configuration built by deep-merging several files.
+type Layered struct {
+	Config *Config
+	// Files lists the merged files in the order they were applied.
+	Files []string
+	// Profiles lists the applied profiles in order.
+	Profiles []string
+
+	root    *yaml.Node
+	origins map[*yaml.Node]string
+}
+
+// LocalPath returns the path of the local override file belonging to base.
+func LocalPath(base string) string {
+	return filepath.Join(filepath.Dir(base), LocalFileName)
+}
+
+// Options selects the files and profiles that make up the effective
+// configuration.
+type Options struct {
+	// Files are merged in order, later files overriding earlier ones.
+	Files []string
+	// Profiles are applied in order on top of the merged files.
+	Profiles []string
+}
+
+// Load merges the configured files in order, later files overriding earlier
+// ones. The local override file next to the first file is applied after them
+// if present, followed by the selected profiles.
+func Load(opts Options) (*Layered, error) {
+	if len(opts.Files) == 0 {
+		return nil, fmt.Errorf("no configuration file given")
+	}
+	files := append([]string{}, opts.Files...)
+	local := LocalPath(opts.Files[0])
+	if _, err := os.Stat(local); err == nil && !contains(files, local) {
+		files = append(files, local)
+	}
+
+	l := &Layered{origins: map[*yaml.Node]string{}}
+	for _, file := range files {
+		node, err := readNode(file)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil {
+			continue
+		}
+		l.add(node, file)
+	}
+	if l.root == nil {
+		l.root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	for _, name := range opts.Profiles {
+		if err := l.applyProfile(name); err != nil {
+			return nil, err
+		}
+	}
+
+	var cfg Config
+	if err := l.root.Decode(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	cfg.dir = filepath.Dir(opts.Files[0])
+	cfg.applyDefaults()
+	l.Config = &cfg
+	return l, nil
+}
+
+// add merges a parsed document into the layered tree.
+func (l *Layered) add(node *yaml.Node, origin string) {
+	l.setOrigin(node, origin)
+	l.Files = append(l.Files, origin)
+	if l.root == nil {
+		l.root = node
+		return
+	}
+	mergeNodes(l.root, node)
+}
+
+// applyProfile merges the named entry of the profiles section on top of the
+// configuration.
+func (l *Layered) applyProfile(name string) error {
+	profile := lookup(l.root, []string{"profiles", name})
+	if profile == nil {
+		return fmt.Errorf("unknown profile %q (available: %s)", name, strings.Join(l.profileNames(), ", "))
+	}
+	if profile.Kind != yaml.MappingNode {
+		return fmt.Errorf("profile %q must be a mapping", name)
+	}
+	if mappingIndex(profile, "profiles") >= 0 {
+		return fmt.Errorf("profile %q must not define profiles", name)
+	}
+	node := cloneNode(profile)
+	l.setOrigin(node, "profile "+name)
+	mergeNodes(l.root, node)
+	l.Profiles = append(l.Profiles, name)
+	return nil
+}
+
+func (l *Layered) profileNames() []string {
+	var names []string
+	if profiles := lookup(l.root, []string{"profiles"}); profiles != nil && profiles.Kind == yaml.MappingNode {
+		for i := 0; i < len(profiles.Content); i += 2 {
+			names = append(names, profiles.Content[i].Value)
+		}
+	}
+	return names
+}
+
+// View renders the effective configuration with the origin of every value
+// attached as a line comment.
+func (l *Layered) View() ([]byte, error) {
+	root := cloneNode(l.root)
+	l.annotate(l.root, root)
+	return encodeNode(root)
+}
+
+// annotate walks src and its clone dst in parallel and records the origin of
+// every non-mapping value on dst.
+func (l *Layered) annotate(src, dst *yaml.Node) {
+	if src.Kind != yaml.MappingNode {
+		if origin, ok := l.origins[src]; ok {
+			dst.LineComment = "from " + origin
+		}
+		return
+	}
+	for i := 1; i < len(src.Content); i += 2 {
+		l.annotate(src.Content[i], dst.Content[i])
+	}
+}
+
+// Origin returns the file that provided the value at the given dotted path,
+// e.g. "postgres.version".
+func (l *Layered) Origin(path string) (string, bool) {
+	node := lookup(l.root, splitPath(path))
+	if node == nil {
+		return "", false
+	}
+	origin, ok := l.origins[node]
+	return origin, ok
+}
+
+// Value renders the effective value at the given dotted path. Scalars are
+// returned as-is, anything else as YAML.
+func (l *Layered) Value(path string) (string, error) {
+	node := lookup(l.root, splitPath(path))
+	if node == nil {
+		return "", fmt.Errorf("%s is not set", path)
+	}
+	if node.Kind == yaml.ScalarNode {
+		return node.Value, nil
+	}
+	data, err := encodeNode(cloneNode(node))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func (l *Layered) setOrigin(node *yaml.Node, origin string) {
+	l.origins[node] = origin
+	for _, child := range node.Content {
+		l.setOrigin(child, origin)
+	}
+}
+
+// readNode parses a YAML file and returns its top-level mapping, or nil when
+// the file is empty.
+func readNode(file string) (*yaml.Node, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s: top-level value must be a mapping", file)
+	}
+	return root, nil
+}
+
+// mergeNodes deep-merges src into dst. Mappings are merged key by key, any
+// other value in src replaces the one in dst.
+func mergeNodes(dst, src *yaml.Node) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		j := mappingIndex(dst, key.Value)
+		if j < 0 {
+			dst.Content = append(dst.Content, key, value)
+			continue
+		}
+		if dst.Content[j+1].Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			mergeNodes(dst.Content[j+1], value)
+			continue
+		}
+		dst.Content[j+1] = value
+	}
+}
+
+// mappingIndex returns the index of key in a mapping node's content, or -1.
+func mappingIndex(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+func lookup(node *yaml.Node, path []string) *yaml.Node {
+	for _, key := range path {
+		if node == nil || node.Kind != yaml.MappingNode {
+			return nil
+		}
+		i := mappingIndex(node, key)
+		if i < 0 {
+			return nil
+		}
+		node = node.Content[i+1]
+	}
+	return node
+}
+
+func cloneNode(src *yaml.Node) *yaml.Node {
+	dst := *src
+	dst.Content = make([]*yaml.Node, len(src.Content))
+	for i, child := range src.Content {
+		dst.Content[i] = cloneNode(child)
+	}
+	return &dst
+}
+
+func encodeNode(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func splitPath(path string) []string {
+	return strings.Split(path, ".")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if filepath.Clean(item) == filepath.Clean(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,370 @@
+// Package helm installs Helm charts with the Helm SDK. Repositories, their
+// indexes and registry logins are kept in kindctl's cache directory, apart
+// from the user's own Helm configuration, so no helm binary is needed.
+package helm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	helmkube "helm.sh/helm/v3/pkg/kube"
+	"helm.sh/helm/v3/pkg/postrender"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	"helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"kindctl/internal/kube"
+	"kindctl/internal/retry"
+)
+
+// Release is a release of a chart to install or render.
+type Release struct {
+	Name      string
+	Namespace string
+	Chart     *chart.Chart
+	Values    map[string]interface{}
+	// PostRender, if set, rewrites the rendered manifests before they are
+	// installed.
+	PostRender func(manifest []byte) ([]byte, error)
+}
+
+// postRenderer adapts a PostRender function to the Helm SDK.
+type postRenderer func(manifest []byte) ([]byte, error)
+
+func (f postRenderer) Run(rendered *bytes.Buffer) (*bytes.Buffer, error) {
+	manifest, err := f(rendered.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewBuffer(manifest), nil
+}
+
+// postRenderer returns the post-renderer of the release, or nil.
+func (rel Release) postRenderer() postrender.PostRenderer {
+	if rel.PostRender == nil {
+		return nil
+	}
+	return postRenderer(rel.PostRender)
+}
+
+// Client installs releases into one cluster.
+type Client struct {
+	settings *cli.EnvSettings
+	registry *registry.Client
+	// debugf receives the log output of the Helm SDK.
+	debugf func(format string, args ...interface{})
+	// actionConfig, if set, replaces the action configuration of the
+	// cluster, so tests can use in-memory releases.
+	actionConfig func(namespace string) (*action.Configuration, error)
+}
+
+// Dir returns the directory kindctl keeps its Helm repositories in.
+func Dir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kindctl", "helm"), nil
+}
+
+// New returns a client for the kubeconfig context of the Kind cluster, or
+// for the current context if clusterName is empty, which is enough to
+// render and pull charts. debugf, if set, receives the log output of the
+// Helm SDK.
+func New(clusterName string, debugf func(format string, args ...interface{})) (*Client, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	settings := cli.New()
+	if clusterName != "" {
+		settings.KubeContext = kube.Context(clusterName)
+	}
+	settings.RepositoryConfig = filepath.Join(dir, "repositories.yaml")
+	settings.RepositoryCache = filepath.Join(dir, "repository")
+	settings.RegistryConfig = filepath.Join(dir, "registry", "config.json")
+	registryClient, err := registry.NewClient(registry.ClientOptCredentialsFile(settings.RegistryConfig))
+	if err != nil {
+		return nil, err
+	}
+	if debugf == nil {
+		debugf = func(string, ...interface{}) {}
+	}
+	return &Client{settings: settings, registry: registryClient, debugf: debugf}, nil
+}
+
+// config returns the action configuration for releases in namespace.
+func (c *Client) config(namespace string) (*action.Configuration, error) {
+	if c.actionConfig != nil {
+		return c.actionConfig(namespace)
+	}
+	cfg := &action.Configuration{RegistryClient: c.registry}
+	getter := helmkube.GetConfig(c.settings.KubeConfig, c.settings.KubeContext, namespace)
+	if err := cfg.Init(getter, namespace, "secret", c.debugf); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+var (
+	// repoMu serializes changes to the repository file.
+	repoMu sync.Mutex
+	// indexed maps the repositories whose index was downloaded by this
+	// process to their URLs.
+	indexed = map[string]string{}
+)
+
+// AddRepo adds or updates a chart repository and downloads its index. The
+// index of a repository is downloaded at most once per process; AddRepo
+// reports whether it did so.
+func (c *Client) AddRepo(ctx context.Context, name, url string) (bool, error) {
+	repoMu.Lock()
+	defer repoMu.Unlock()
+	if indexed[name] == url {
+		return false, nil
+	}
+	entry := &repo.Entry{Name: name, URL: url}
+	r, err := repo.NewChartRepository(entry, getter.All(c.settings))
+	if err != nil {
+		return false, err
+	}
+	r.CachePath = c.settings.RepositoryCache
+	err = retry.Do(ctx, func() error {
+		_, err := r.DownloadIndexFile()
+		return err
+	})
+	if err != nil {
+		return false, fmt.Errorf("downloading index of repository %s: %w", name, err)
+	}
+	f, err := repo.LoadFile(c.settings.RepositoryConfig)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = repo.NewFile(), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	f.Update(entry)
+	if err := os.MkdirAll(filepath.Dir(c.settings.RepositoryConfig), 0755); err != nil {
+		return false, err
+	}
+	if err := f.WriteFile(c.settings.RepositoryConfig, 0644); err != nil {
+		return false, err
+	}
+	indexed[name] = url
+	return true, nil
+}
+
+// Load returns the chart a reference points to: a repo/name reference of an
+// added repository, an oci:// reference, or a local archive or directory.
+// Remote charts are downloaded into the repository cache.
+func (c *Client) Load(ctx context.Context, ref, version string) (*chart.Chart, error) {
+	install := action.NewInstall(&action.Configuration{RegistryClient: c.registry})
+	install.Version = version
+	var path string
+	err := retry.Do(ctx, func() error {
+		var err error
+		path, err = install.LocateChart(ref, c.settings)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loader.Load(path)
+}
+
+// Pull downloads a chart archive into dir.
+func (c *Client) Pull(ctx context.Context, ref, version, dir string) error {
+	pull := action.NewPullWithOpts(action.WithConfig(&action.Configuration{RegistryClient: c.registry}))
+	pull.Settings = c.settings
+	pull.Version = version
+	pull.DestDir = dir
+	return retry.Do(ctx, func() error {
+		_, err := pull.Run(ref)
+		return err
+	})
+}
+
+// Upgrade installs the release or upgrades it if it exists, like helm
+// upgrade --install, and returns the deployed release. A deployed release
+// whose manifest, values and chart version would not change is left at its
+// revision; Upgrade reports whether it installed or upgraded anything.
+func (c *Client) Upgrade(ctx context.Context, rel Release) (*release.Release, bool, error) {
+	cfg, err := c.config(rel.Namespace)
+	if err != nil {
+		return nil, false, err
+	}
+	var deployed *release.Release
+	changed := false
+	err = retry.Do(ctx, func() error {
+		current, err := action.NewGet(cfg).Run(rel.Name)
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			install := action.NewInstall(cfg)
+			install.ReleaseName = rel.Name
+			install.Namespace = rel.Namespace
+			install.CreateNamespace = true
+			install.PostRenderer = rel.postRenderer()
+			deployed, err = install.RunWithContext(ctx, rel.Chart, rel.Values)
+			changed = err == nil
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		upgrade := action.NewUpgrade(cfg)
+		upgrade.Namespace = rel.Namespace
+		upgrade.PostRenderer = rel.postRenderer()
+		if current.Info != nil && current.Info.Status == release.StatusDeployed {
+			// Render the upgrade against the cluster first and keep the
+			// current revision if it would not change anything.
+			upgrade.DryRun, upgrade.DryRunOption = true, "server"
+			planned, err := upgrade.RunWithContext(ctx, rel.Name, rel.Chart, rel.Values)
+			if err != nil {
+				return err
+			}
+			if sameRelease(current, planned) {
+				deployed = current
+				return nil
+			}
+			upgrade.DryRun, upgrade.DryRunOption = false, ""
+		}
+		deployed, err = upgrade.RunWithContext(ctx, rel.Name, rel.Chart, rel.Values)
+		changed = err == nil
+		return err
+	})
+	return deployed, changed, err
+}
+
+// sameRelease reports whether two revisions of a release deploy the same
+// manifest with the same values and chart version.
+func sameRelease(a, b *release.Release) bool {
+	if a.Manifest != b.Manifest || a.Chart == nil || b.Chart == nil ||
+		a.Chart.Metadata == nil || b.Chart.Metadata == nil ||
+		a.Chart.Metadata.Version != b.Chart.Metadata.Version {
+		return false
+	}
+	return sameValues(a.Config, b.Config)
+}
+
+// sameValues compares chart values by their JSON encoding, the form Helm
+// stores them in, so numbers read back from a release compare equal to
+// the values they were installed with.
+func sameValues(a, b map[string]interface{}) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return len(a) == len(b)
+	}
+	encodedA, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	encodedB, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(encodedA, encodedB)
+}
+
+// Template renders the manifests of the release without contacting the
+// cluster, like helm template.
+func (c *Client) Template(ctx context.Context, rel Release) ([]byte, error) {
+	install := action.NewInstall(&action.Configuration{Log: c.debugf})
+	install.ReleaseName = rel.Name
+	install.Namespace = rel.Namespace
+	install.DryRun = true
+	install.ClientOnly = true
+	install.Replace = true
+	install.PostRenderer = rel.postRenderer()
+	rendered, err := install.RunWithContext(ctx, rel.Chart, rel.Values)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(rendered.Manifest), nil
+}
+
+// Uninstall removes the release and reports whether it existed.
+func (c *Client) Uninstall(ctx context.Context, name, namespace string) (bool, error) {
+	cfg, err := c.config(namespace)
+	if err != nil {
+		return false, err
+	}
+	err = retry.Do(ctx, func() error {
+		_, err := action.NewUninstall(cfg).Run(name)
+		return err
+	})
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Status returns the latest revision of the release, or nil if it is not
+// installed.
+func (c *Client) Status(ctx context.Context, name, namespace string) (*release.Release, error) {
+	cfg, err := c.config(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var rel *release.Release
+	err = retry.Do(ctx, func() error {
+		rel, err = action.NewStatus(cfg).Run(name)
+		return err
+	})
+	if errors.Is(err, driver.ErrReleaseNotFound) {
+		return nil, nil
+	}
+	return rel, err
+}
+
+// Values returns the values the release was installed with.
+func (c *Client) Values(ctx context.Context, name, namespace string) (map[string]interface{}, error) {
+	cfg, err := c.config(namespace)
+	if err != nil {
+		return nil, err
+	}
+	var values map[string]interface{}
+	err = retry.Do(ctx, func() error {
+		values, err = action.NewGetValues(cfg).Run(name)
+		return err
+	})
+	return values, err
+}
+
+// ReadValuesFile reads a YAML values file.
+func ReadValuesFile(path string) (map[string]interface{}, error) {
+	values, err := chartutil.ReadValuesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// MergeValues returns base with override merged in. Nested maps are merged
+// key by key and any other value in override replaces the one in base, like
+// passing several values files to helm. Neither argument is modified.
+func MergeValues(base, override map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		if overrideMap, ok := value.(map[string]interface{}); ok {
+			if baseMap, ok := merged[key].(map[string]interface{}); ok {
+				merged[key] = MergeValues(baseMap, overrideMap)
+				continue
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
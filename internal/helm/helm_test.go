@@ -0,0 +1,139 @@
+package helm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chartutil"
+	kubefake "helm.sh/helm/v3/pkg/kube/fake"
+	"helm.sh/helm/v3/pkg/storage"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+func writeChart(t *testing.T) string {
+	dir := filepath.Join(t.TempDir(), "demo")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "templates"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Chart.yaml"), []byte("apiVersion: v2\nname: demo\nversion: 1.2.3\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "values.yaml"), []byte("greeting: hello\nimage:\n  tag: latest\n"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "templates", "cm.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+  namespace: {{ .Release.Namespace }}
+data:
+  greeting: {{ .Values.greeting }}
+  tag: {{ .Values.image.tag }}
+`), 0644))
+	return dir
+}
+
+func TestTemplate(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	client, err := New("", nil)
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	loaded, err := client.Load(ctx, writeChart(t), "")
+	assert.NoError(t, err)
+	manifest, err := client.Template(ctx, Release{Name: "demo", Namespace: "tools", Chart: loaded,
+		Values: map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}}})
+	assert.NoError(t, err)
+	assert.Contains(t, string(manifest), "name: demo\n  namespace: tools\n")
+	assert.Contains(t, string(manifest), "greeting: hello\n  tag: 1.0\n")
+}
+
+func TestUpgrade(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	client, err := New("", nil)
+	assert.NoError(t, err)
+	releases := storage.Init(driver.NewMemory())
+	client.actionConfig = func(string) (*action.Configuration, error) {
+		return &action.Configuration{
+			Releases:     releases,
+			KubeClient:   &kubefake.PrintingKubeClient{Out: io.Discard},
+			Capabilities: chartutil.DefaultCapabilities,
+			Log:          func(string, ...interface{}) {},
+		}, nil
+	}
+	ctx := context.Background()
+	loaded, err := client.Load(ctx, writeChart(t), "")
+	assert.NoError(t, err)
+	rel := Release{Name: "demo", Namespace: "tools", Chart: loaded,
+		Values: map[string]interface{}{"image": map[string]interface{}{"tag": "1.0"}, "replicas": 2}}
+
+	deployed, changed, err := client.Upgrade(ctx, rel)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 1, deployed.Version)
+
+	deployed, changed, err = client.Upgrade(ctx, rel)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 1, deployed.Version)
+
+	rel.Values = map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}, "replicas": 2}
+	deployed, changed, err = client.Upgrade(ctx, rel)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 2, deployed.Version)
+	assert.Contains(t, deployed.Manifest, "tag: 2.0")
+
+	rel.Values = map[string]interface{}{"image": map[string]interface{}{"tag": "2.0"}, "replicas": 3}
+	deployed, changed, err = client.Upgrade(ctx, rel)
+	assert.NoError(t, err)
+	assert.True(t, changed, "values changed even though the manifest did not")
+	assert.Equal(t, 3, deployed.Version)
+}
+
+func TestAddRepo(t *testing.T) {
+	cache := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cache)
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/index.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("apiVersion: v1\nentries: {}\n"))
+	}))
+	defer server.Close()
+
+	client, err := New("", nil)
+	assert.NoError(t, err)
+	fetched, err := client.AddRepo(context.Background(), "demo", server.URL)
+	assert.NoError(t, err)
+	assert.True(t, fetched)
+	fetched, err = client.AddRepo(context.Background(), "demo", server.URL)
+	assert.NoError(t, err)
+	assert.False(t, fetched)
+	assert.Equal(t, 1, requests)
+	data, err := os.ReadFile(filepath.Join(cache, "kindctl", "helm", "repositories.yaml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "url: "+server.URL)
+	assert.FileExists(t, filepath.Join(cache, "kindctl", "helm", "repository", "demo-index.yaml"))
+}
+
+func TestMergeValues(t *testing.T) {
+	base := map[string]interface{}{
+		"auth":  map[string]interface{}{"username": "app", "password": "secret"},
+		"image": "postgres",
+	}
+	merged := MergeValues(base, map[string]interface{}{
+		"auth":    map[string]interface{}{"password": "changed"},
+		"metrics": true,
+	})
+	assert.Equal(t, map[string]interface{}{
+		"auth":    map[string]interface{}{"username": "app", "password": "changed"},
+		"image":   "postgres",
+		"metrics": true,
+	}, merged)
+	assert.Equal(t, "secret", base["auth"].(map[string]interface{})["password"])
+}
@@ -0,0 +1,65 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/containerd/containerd/reference/docker"
+	remotes "github.com/containerd/containerd/remotes/docker"
+	"kindctl/internal/retry"
+)
+
+// Digest looks up the digest an image reference currently points to in its
+// registry, without pulling the image.
+func Digest(ctx context.Context, image string) (string, error) {
+	named, err := docker.ParseDockerRef(image)
+	if err != nil {
+		return "", err
+	}
+	resolver := remotes.NewResolver(remotes.ResolverOptions{})
+	var digest string
+	err = retry.Do(ctx, func() error {
+		_, desc, err := resolver.Resolve(ctx, named.String())
+		if err != nil {
+			return err
+		}
+		digest = desc.Digest.String()
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("resolving digest of %s: %w", image, err)
+	}
+	return digest, nil
+}
+
+// Pinned returns the image reference pinned to digest, e.g.
+// axllent/mailpit:latest@sha256:...; references that already carry a digest
+// are returned unchanged.
+func Pinned(image, digest string) string {
+	if digest == "" || strings.Contains(image, "@") {
+		return image
+	}
+	return image + "@" + digest
+}
+
+// imageLine matches the image fields of a rendered manifest.
+var imageLine = regexp.MustCompile(`(?m)^(\s*(?:-\s+)?image:\s*)(["']?)([^"'\s#]+)(["']?)(\s*)$`)
+
+// Pin rewrites the image fields of a manifest to the digests in digests,
+// keyed by the image reference. Other images are left alone.
+func Pin(manifest []byte, digests map[string]string) []byte {
+	if len(digests) == 0 {
+		return manifest
+	}
+	return imageLine.ReplaceAllFunc(manifest, func(line []byte) []byte {
+		m := imageLine.FindSubmatch(line)
+		image := string(m[3])
+		digest, ok := digests[image]
+		if !ok {
+			return line
+		}
+		return []byte(string(m[1]) + string(m[2]) + Pinned(image, digest) + string(m[4]) + string(m[5]))
+	})
+}
@@ -0,0 +1,133 @@
+// Package images pulls container images into the local Docker cache and
+// side-loads them into the nodes of a Kind cluster.
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+	"kindctl/internal/command"
+	"kindctl/internal/logger"
+)
+
+// Extract returns the container images referenced by a multi-document
+// Kubernetes manifest, sorted and without duplicates.
+func Extract(manifest []byte) ([]string, error) {
+	seen := map[string]bool{}
+	dec := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var doc interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		collect(doc, seen)
+	}
+	images := make([]string, 0, len(seen))
+	for image := range seen {
+		images = append(images, image)
+	}
+	sort.Strings(images)
+	return images, nil
+}
+
+// collect records the string values of every "image" key below node.
+func collect(node interface{}, seen map[string]bool) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, value := range n {
+			if image, ok := value.(string); ok && key == "image" && image != "" {
+				seen[image] = true
+				continue
+			}
+			collect(value, seen)
+		}
+	case []interface{}:
+		for _, item := range n {
+			collect(item, seen)
+		}
+	}
+}
+
+// Present reports whether the image is in the local Docker image cache.
+func Present(ctx context.Context, image string) bool {
+	return command.New(ctx, "docker", "image", "inspect", image).Run() == nil
+}
+
+// Pull makes sure the images are in the local Docker image cache, pulling
+// the missing ones unless offline is set.
+func Pull(ctx context.Context, log *logger.Logger, images []string, offline bool) error {
+	for _, image := range images {
+		if Present(ctx, image) {
+			log.Debugf("Image %s is already present", image)
+			continue
+		}
+		if offline {
+			return fmt.Errorf("image %s is not in the local Docker cache and --offline forbids pulling it", image)
+		}
+		log.Infof("Pulling image %s", image)
+		err := command.Run(ctx, func() *exec.Cmd {
+			cmd := command.New(ctx, "docker", "pull", image)
+			cmd.Stdout = os.Stdout
+			cmd.Stderr = os.Stderr
+			return cmd
+		})
+		if err != nil {
+			return fmt.Errorf("pulling %s: %w", image, err)
+		}
+	}
+	return nil
+}
+
+// Load side-loads images from the local Docker cache into the nodes of the
+// Kind cluster. Images kind cannot load directly, such as some multi-platform
+// images, are imported from an archive instead.
+func Load(ctx context.Context, log *logger.Logger, clusterName string, images []string) error {
+	for _, image := range images {
+		err := command.Run(ctx, func() *exec.Cmd {
+			return command.New(ctx, "kind", "load", "docker-image", image, "--name", clusterName)
+		})
+		if err != nil {
+			log.Debugf("kind load docker-image %s failed, importing an archive instead: %v", image, err)
+			if err := loadArchive(ctx, clusterName, image); err != nil {
+				return fmt.Errorf("loading %s: %w", image, err)
+			}
+		}
+		log.Infof("Loaded image %s into cluster %s", image, clusterName)
+	}
+	return nil
+}
+
+func loadArchive(ctx context.Context, clusterName, image string) error {
+	archive, err := os.CreateTemp("", "kindctl-image-*.tar")
+	if err != nil {
+		return err
+	}
+	archive.Close()
+	defer os.Remove(archive.Name())
+
+	err = command.Run(ctx, func() *exec.Cmd {
+		cmd := command.New(ctx, "docker", "save", "-o", archive.Name(), image)
+		cmd.Stderr = os.Stderr
+		return cmd
+	})
+	if err != nil {
+		return err
+	}
+	return command.Run(ctx, func() *exec.Cmd {
+		cmd := command.New(ctx, "kind", "load", "image-archive", archive.Name(), "--name", clusterName)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		return cmd
+	})
+}
@@ -0,0 +1,66 @@
+package images
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtract(t *testing.T) {
+	manifest := `
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox:1.36
+      containers:
+      - name: app
+        image: adminer:4.8.1
+---
+# Empty document
+---
+apiVersion: apps/v1
+kind: StatefulSet
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: adminer:4.8.1
+`
+	images, err := Extract([]byte(manifest))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"adminer:4.8.1", "busybox:1.36"}, images)
+}
+
+func TestPin(t *testing.T) {
+	manifest := `containers:
+- name: app
+  image: adminer:4.8.1
+- name: quoted
+  image: "busybox:1.36"
+- name: other
+  image: nginx:1.25
+- name: pinned
+  image: redis:7@sha256:def
+`
+	pinned := Pin([]byte(manifest), map[string]string{
+		"adminer:4.8.1":      "sha256:abc",
+		"busybox:1.36":       "sha256:123",
+		"redis:7@sha256:def": "sha256:456",
+	})
+	assert.Equal(t, `containers:
+- name: app
+  image: adminer:4.8.1@sha256:abc
+- name: quoted
+  image: "busybox:1.36@sha256:123"
+- name: other
+  image: nginx:1.25
+- name: pinned
+  image: redis:7@sha256:def
+`, string(pinned))
+	assert.Equal(t, "adminer:4.8.1", Pinned("adminer:4.8.1", ""))
+}
@@ -1,6 +1,7 @@
 package ingress
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -11,6 +12,6 @@ func TestAddHostEntry(t *testing.T) {
 	log := logger.NewLogger("debug")
 	// Note: Actual /etc/hosts modification requires sudo, tested in integration tests.
 	// Expect error in test environment due to permissions.
-	err := AddHostEntry(log, "test.local")
+	err := AddHostEntry(context.Background(), log, "test.local")
 	assert.Error(t, err)
 }
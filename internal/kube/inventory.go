@@ -0,0 +1,96 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"kindctl/internal/retry"
+)
+
+// inventoryKey is the ConfigMap key that holds the recorded objects.
+const inventoryKey = "objects"
+
+// inventoryName names the ConfigMap that records the objects of a tool.
+func inventoryName(name string) string {
+	return "kindctl-" + name + "-inventory"
+}
+
+// SaveInventory records the objects of a manifest applied for the tool name
+// in a ConfigMap in namespace, so that DeleteInventory can remove them
+// without the manifest.
+func (c *Client) SaveInventory(ctx context.Context, namespace, name string, manifest []byte) error {
+	objects, err := Decode(manifest)
+	if err != nil {
+		return err
+	}
+	items := make([]map[string]interface{}, 0, len(objects))
+	for _, obj := range objects {
+		metadata := map[string]interface{}{"name": obj.GetName()}
+		if obj.GetNamespace() != "" {
+			metadata["namespace"] = obj.GetNamespace()
+		}
+		items = append(items, map[string]interface{}{
+			"apiVersion": obj.GetAPIVersion(),
+			"kind":       obj.GetKind(),
+			"metadata":   metadata,
+		})
+	}
+	data, err := json.Marshal(map[string]interface{}{"apiVersion": "v1", "kind": "List", "items": items})
+	if err != nil {
+		return err
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inventoryName(name),
+			Namespace: namespace,
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": FieldManager},
+		},
+		Data: map[string]string{inventoryKey: string(data)},
+	}
+	err = retry.Do(ctx, func() error {
+		configMaps := c.Clientset.CoreV1().ConfigMaps(namespace)
+		_, err := configMaps.Create(ctx, cm, metav1.CreateOptions{FieldManager: FieldManager})
+		if apierrors.IsAlreadyExists(err) {
+			_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{FieldManager: FieldManager})
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("saving inventory of %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteInventory deletes the objects recorded for the tool name and then
+// the record itself. It reports whether a record existed, which is a cheap
+// way to tell whether the tool is installed.
+func (c *Client) DeleteInventory(ctx context.Context, namespace, name string, out io.Writer) (bool, error) {
+	configMaps := c.Clientset.CoreV1().ConfigMaps(namespace)
+	var cm *corev1.ConfigMap
+	err := retry.Do(ctx, func() error {
+		var err error
+		cm, err = configMaps.Get(ctx, inventoryName(name), metav1.GetOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading inventory of %s: %w", name, err)
+	}
+	if _, err := c.Delete(ctx, []byte(cm.Data[inventoryKey]), out); err != nil {
+		return true, err
+	}
+	err = retry.Do(ctx, func() error {
+		return configMaps.Delete(ctx, inventoryName(name), metav1.DeleteOptions{})
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return true, fmt.Errorf("deleting inventory of %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,248 @@
+// Package kube talks to the API server of the Kind cluster directly, so that
+// kindctl does not need a kubectl binary.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	"kindctl/internal/retry"
+)
+
+// FieldManager owns the fields kindctl applies.
+const FieldManager = "kindctl"
+
+// defaultNamespace is used for namespaced objects without a namespace.
+const defaultNamespace = "default"
+
+// Client applies and deletes objects in one cluster.
+type Client struct {
+	Clientset kubernetes.Interface
+	dynamic   dynamic.Interface
+	mapper    meta.RESTMapper
+}
+
+// Context returns the kubeconfig context kind creates for the cluster.
+func Context(clusterName string) string {
+	return "kind-" + clusterName
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = map[string]*Client{}
+)
+
+// ForCluster returns the client for the kubeconfig context of the Kind
+// cluster, honouring $KUBECONFIG. Clients are created once per cluster.
+func ForCluster(clusterName string) (*Client, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if c, ok := clients[clusterName]; ok {
+		return c, nil
+	}
+	config, err := RESTConfig(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	clients[clusterName] = c
+	return c, nil
+}
+
+// RESTConfig loads the kubeconfig context of the Kind cluster.
+func RESTConfig(clusterName string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: Context(clusterName)}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("loading kubeconfig context %s: %w", Context(clusterName), err)
+	}
+	return config, nil
+}
+
+// NewForConfig creates a client for the given API server.
+func NewForConfig(config *rest.Config) (*Client, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	dyn, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery()))
+	return New(clientset, dyn, mapper), nil
+}
+
+// New creates a client from its parts, e.g. fake clients in tests.
+func New(clientset kubernetes.Interface, dyn dynamic.Interface, mapper meta.RESTMapper) *Client {
+	return &Client{Clientset: clientset, dynamic: dyn, mapper: mapper}
+}
+
+// Decode splits a YAML or JSON manifest into its objects, skipping empty
+// documents.
+func Decode(manifest []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(manifest), 4096)
+	var objects []*unstructured.Unstructured
+	for {
+		var obj map[string]interface{}
+		if err := decoder.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("decoding manifest: %w", err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		if u.IsList() {
+			list, err := u.ToList()
+			if err != nil {
+				return nil, err
+			}
+			for i := range list.Items {
+				objects = append(objects, &list.Items[i])
+			}
+			continue
+		}
+		if u.GetKind() == "" || u.GetName() == "" {
+			return nil, fmt.Errorf("decoding manifest: object without kind or name")
+		}
+		objects = append(objects, u)
+	}
+}
+
+// Ref names an object the way kubectl prints it, e.g. deployment.apps/mailpit.
+func Ref(obj *unstructured.Unstructured) string {
+	return strings.ToLower(obj.GroupVersionKind().GroupKind().String()) + "/" + obj.GetName()
+}
+
+// resource returns the client for the object's resource, defaulting the
+// namespace of namespaced objects.
+func (c *Client) resource(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if resettable, ok := c.mapper.(meta.ResettableRESTMapper); ok && meta.IsNoMatchError(err) {
+		// The kind may come from a CustomResourceDefinition applied earlier.
+		resettable.Reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", Ref(obj), err)
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return c.dynamic.Resource(mapping.Resource), nil
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(defaultNamespace)
+	}
+	return c.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// Apply server-side applies the objects of the manifest in order and writes
+// a line such as "deployment.apps/mailpit created" for each to out.
+func (c *Client) Apply(ctx context.Context, manifest []byte, out io.Writer) error {
+	objects, err := Decode(manifest)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objects {
+		change, err := c.apply(ctx, obj)
+		if err != nil {
+			return fmt.Errorf("applying %s: %w", Ref(obj), err)
+		}
+		fmt.Fprintf(out, "%s %s\n", Ref(obj), change)
+	}
+	return nil
+}
+
+// apply applies one object and reports whether it was created, configured
+// or unchanged.
+func (c *Client) apply(ctx context.Context, obj *unstructured.Unstructured) (string, error) {
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	var change string
+	err = retry.Do(ctx, func() error {
+		res, err := c.resource(obj)
+		if err != nil {
+			return err
+		}
+		before := ""
+		existing, err := res.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+		case err != nil:
+			return err
+		default:
+			before = existing.GetResourceVersion()
+		}
+		force := true
+		applied, err := res.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{FieldManager: FieldManager, Force: &force})
+		if err != nil {
+			return err
+		}
+		switch {
+		case before == "":
+			change = "created"
+		case applied.GetResourceVersion() == before:
+			change = "unchanged"
+		default:
+			change = "configured"
+		}
+		return nil
+	})
+	return change, err
+}
+
+// Delete deletes the objects of the manifest in reverse order, ignoring
+// the ones that do not exist, and writes a line for each deleted object to
+// out. It returns the number of deleted objects.
+func (c *Client) Delete(ctx context.Context, manifest []byte, out io.Writer) (int, error) {
+	objects, err := Decode(manifest)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for i := len(objects) - 1; i >= 0; i-- {
+		obj := objects[i]
+		err := retry.Do(ctx, func() error {
+			res, err := c.resource(obj)
+			if err != nil {
+				return err
+			}
+			return res.Delete(ctx, obj.GetName(), metav1.DeleteOptions{})
+		})
+		if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			continue
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("deleting %s: %w", Ref(obj), err)
+		}
+		fmt.Fprintf(out, "%s deleted\n", Ref(obj))
+		deleted++
+	}
+	return deleted, nil
+}
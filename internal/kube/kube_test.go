@@ -0,0 +1,78 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func TestDecode(t *testing.T) {
+	objects, err := Decode([]byte(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: mailpit
+---
+---
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: mailpit
+- apiVersion: networking.k8s.io/v1
+  kind: Ingress
+  metadata:
+    name: mailpit-ingress
+    namespace: tools
+`))
+	assert.NoError(t, err)
+	var refs []string
+	for _, obj := range objects {
+		refs = append(refs, Ref(obj))
+	}
+	assert.Equal(t, []string{"deployment.apps/mailpit", "service/mailpit", "ingress.networking.k8s.io/mailpit-ingress"}, refs)
+	assert.Equal(t, "tools", objects[2].GetNamespace())
+
+	_, err = Decode([]byte("apiVersion: v1\nkind: ConfigMap\n"))
+	assert.Error(t, err)
+}
+
+func TestDelete(t *testing.T) {
+	configMaps := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	existing := &unstructured.Unstructured{}
+	existing.SetAPIVersion("v1")
+	existing.SetKind("ConfigMap")
+	existing.SetName("present")
+	existing.SetNamespace("default")
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{configMaps: "ConfigMapList"}, existing)
+	c := &Client{dynamic: dyn, mapper: mapper}
+
+	var out bytes.Buffer
+	deleted, err := c.Delete(context.Background(), []byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: present
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: missing
+`), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	assert.Equal(t, "configmap/present deleted\n", out.String())
+}
@@ -0,0 +1,78 @@
+// Package lock reads and writes kindctl.lock, which records the chart
+// versions and image digests the tools were installed with so that
+// everyone sharing a configuration gets the same versions.
+package lock
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the name of the lockfile next to the configuration file.
+const FileName = "kindctl.lock"
+
+const header = `# Generated by kindctl. Commit this file so everyone installs the same chart
+# versions and images; refresh it with "kindctl lock update [tool]".
+`
+
+// File is the content of a lockfile.
+type File struct {
+	Tools map[string]*Tool `yaml:"tools"`
+}
+
+// Tool records what one tool was installed with.
+type Tool struct {
+	// Chart is the chart reference, e.g. bitnami/postgresql, and Version
+	// the resolved chart version.
+	Chart   string `yaml:"chart,omitempty"`
+	Version string `yaml:"version,omitempty"`
+	// Images maps the images the tool runs to their digests.
+	Images map[string]string `yaml:"images,omitempty"`
+}
+
+// Load reads a lockfile. A missing file is an empty lockfile.
+func Load(path string) (*File, error) {
+	f := &File{Tools: map[string]*Tool{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if f.Tools == nil {
+		f.Tools = map[string]*Tool{}
+	}
+	return f, nil
+}
+
+// Save writes the lockfile.
+func (f *File) Save(path string) error {
+	var buf bytes.Buffer
+	buf.WriteString(header)
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Tool returns the entry of a tool, creating it if needed.
+func (f *File) Tool(name string) *Tool {
+	t, ok := f.Tools[name]
+	if !ok {
+		t = &Tool{}
+		f.Tools[name] = t
+	}
+	return t
+}
@@ -0,0 +1,35 @@
+package lock
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	f, err := Load(path)
+	assert.NoError(t, err)
+	assert.Empty(t, f.Tools)
+
+	entry := f.Tool("postgres")
+	entry.Chart, entry.Version = "bitnami/postgresql", "15.5.1"
+	entry.Images = map[string]string{"bitnami/postgresql:16.3.0": "sha256:abc"}
+	assert.Same(t, entry, f.Tool("postgres"))
+	assert.NoError(t, f.Save(path))
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# Generated by kindctl."))
+
+	loaded, err := Load(path)
+	assert.NoError(t, err)
+	assert.Equal(t, f, loaded)
+
+	assert.NoError(t, os.WriteFile(path, []byte("tools: [\n"), 0644))
+	_, err = Load(path)
+	assert.Error(t, err)
+}
@@ -1,13 +1,16 @@
 package logger
 
 import (
+	"io"
+	"os"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
-	"os"
 )
 
 type Logger struct {
 	*zap.SugaredLogger
+	level zapcore.Level
 }
 
 func NewLogger(level string) *Logger {
@@ -25,6 +28,15 @@ func NewLogger(level string) *Logger {
 		lvl = zapcore.InfoLevel
 	}
 
+	return newLogger(lvl, os.Stdout)
+}
+
+// To returns a logger with the same level writing to w.
+func (l *Logger) To(w io.Writer) *Logger {
+	return newLogger(l.level, w)
+}
+
+func newLogger(lvl zapcore.Level, w io.Writer) *Logger {
 	encoderConfig := zapcore.EncoderConfig{
 		MessageKey:     "msg",
 		LevelKey:       "level",
@@ -37,11 +49,11 @@ func NewLogger(level string) *Logger {
 
 	core := zapcore.NewCore(
 		encoder,
-		zapcore.Lock(os.Stdout),
+		zapcore.Lock(zapcore.AddSync(w)),
 		lvl,
 	)
 
 	// Build logger
 	logger := zap.New(core, zap.AddCallerSkip(1))
-	return &Logger{logger.Sugar()}
+	return &Logger{logger.Sugar(), lvl}
 }
@@ -0,0 +1,30 @@
+// Command fetch downloads the pinned upstream manifests embedded into
+// kindctl and checks them against upstream/SHA256SUMS. It is run by
+// go generate ./internal/manifests; -pin records the sums of the downloaded
+// manifests instead, after a URL in manifests.go was changed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"kindctl/internal/manifests"
+)
+
+func main() {
+	pin := flag.Bool("pin", false, "record the SHA-256 sums of the downloaded manifests")
+	flag.Parse()
+	// The upstream directory lives next to manifests.go, wherever this is
+	// run from.
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "upstream")
+	logf := func(format string, args ...interface{}) { fmt.Fprintf(os.Stderr, format+"\n", args...) }
+	if err := manifests.Fetch(context.Background(), dir, *pin, logf); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,206 @@
+// Package manifests provides the pinned upstream manifests kindctl applies.
+package manifests
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"kindctl/internal/retry"
+)
+
+//go:generate go run ./fetch
+
+//go:embed all:upstream
+var upstream embed.FS
+
+// Manifest is an upstream manifest pinned to a release.
+type Manifest struct {
+	Name string
+	URL  string
+	file string
+}
+
+var (
+	// IngressNginx is the NGINX ingress controller for Kind.
+	IngressNginx = Manifest{
+		Name: "ingress-nginx",
+		URL:  "https://raw.githubusercontent.com/kubernetes/ingress-nginx/controller-v1.11.3/deploy/static/provider/kind/deploy.yaml",
+		file: "upstream/ingress-nginx-kind.yaml",
+	}
+	// Dashboard is the Kubernetes Dashboard.
+	Dashboard = Manifest{
+		Name: "kubernetes-dashboard",
+		URL:  "https://raw.githubusercontent.com/kubernetes/dashboard/v2.7.0/aio/deploy/recommended.yaml",
+		file: "upstream/dashboard.yaml",
+	}
+
+	// All lists the manifests embedded into the binary.
+	All = []Manifest{IngressNginx, Dashboard}
+)
+
+// sumsFile lists the SHA-256 sums of the embedded manifests in the format
+// of sha256sum.
+const sumsFile = "upstream/SHA256SUMS"
+
+// sums reads the pinned SHA-256 sums by file name.
+func sums() (map[string]string, error) {
+	data, err := upstream.ReadFile(sumsFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSums(data)
+}
+
+func parseSums(data []byte) (map[string]string, error) {
+	sums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s: malformed line %q", sumsFile, line)
+		}
+		sums[strings.TrimPrefix(fields[1], "*")] = fields[0]
+	}
+	return sums, scanner.Err()
+}
+
+// verify checks data against the sum pinned for the manifest.
+func (m Manifest) verify(data []byte, sums map[string]string) error {
+	want, ok := sums[path.Base(m.file)]
+	if !ok {
+		return fmt.Errorf("no SHA-256 sum is pinned for the %s manifest in %s", m.Name, sumsFile)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return fmt.Errorf("%s manifest has SHA-256 %s, want %s", m.Name, got, want)
+	}
+	return nil
+}
+
+// Embedded reports whether the manifest was built into the binary.
+func (m Manifest) Embedded() bool {
+	_, err := fs.Stat(upstream, m.file)
+	return err == nil
+}
+
+// Load returns the embedded manifest. When the binary was built without it,
+// the manifest is downloaded unless offline is set, and checked against its
+// pinned SHA-256 sum.
+func (m Manifest) Load(ctx context.Context, offline bool) ([]byte, error) {
+	data, err := upstream.ReadFile(m.file)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if offline {
+		return nil, fmt.Errorf("%s manifest is not embedded in this build and --offline forbids downloading it", m.Name)
+	}
+	pinned, err := sums()
+	if err != nil {
+		return nil, err
+	}
+	data, err = Download(ctx, m.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(data, pinned); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Fetch downloads the manifests to embed into dir, the upstream directory
+// of this package, and checks them against the sums pinned in SHA256SUMS.
+// With pin, the sums of the downloaded manifests are written to SHA256SUMS
+// instead; review the changes before committing them.
+func Fetch(ctx context.Context, dir string, pin bool, logf func(format string, args ...interface{})) error {
+	sumsPath := filepath.Join(dir, path.Base(sumsFile))
+	pinned := map[string]string{}
+	if data, err := os.ReadFile(sumsPath); err == nil {
+		if pinned, err = parseSums(data); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for _, m := range All {
+		logf("Fetching %s...", m.URL)
+		data, err := Download(ctx, m.URL)
+		if err != nil {
+			return err
+		}
+		name := path.Base(m.file)
+		if pin {
+			sum := sha256.Sum256(data)
+			pinned[name] = hex.EncodeToString(sum[:])
+		} else if err := m.verify(data, pinned); err != nil {
+			return fmt.Errorf("%w; after reviewing the new manifest, pin it with go run ./internal/manifests/fetch -pin", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return err
+		}
+	}
+	if !pin {
+		return nil
+	}
+	names := make([]string, 0, len(pinned))
+	for name := range pinned {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var buf bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&buf, "%s  %s\n", pinned[name], name)
+	}
+	return os.WriteFile(sumsPath, buf.Bytes(), 0644)
+}
+
+// Download fetches a manifest over HTTP.
+func Download(ctx context.Context, url string) ([]byte, error) {
+	var data []byte
+	err := retry.Do(ctx, func() error {
+		var err error
+		data, err = download(ctx, url)
+		return err
+	})
+	return data, err
+}
+
+func download(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
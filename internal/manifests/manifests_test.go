@@ -0,0 +1,59 @@
+package manifests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPinnedSums fails while a manifest Load may download has no sum in
+// upstream/SHA256SUMS: go generate and every runtime download of it would
+// fail. Record the sums with go run ./internal/manifests/fetch -pin.
+func TestPinnedSums(t *testing.T) {
+	pinned, err := sums()
+	assert.NoError(t, err)
+	for _, m := range All {
+		assert.Regexp(t, `^[0-9a-f]{64}$`, pinned[path.Base(m.file)], "no SHA-256 sum is pinned for the %s manifest in %s", m.Name, sumsFile)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	sums, err := parseSums([]byte("# pinned\n" +
+		"2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  demo.yaml\n"))
+	assert.NoError(t, err)
+	m := Manifest{Name: "demo", file: "upstream/demo.yaml"}
+	assert.NoError(t, m.verify([]byte("hello"), sums))
+	assert.ErrorContains(t, m.verify([]byte("tampered"), sums), "demo manifest has SHA-256")
+	assert.ErrorContains(t, Manifest{Name: "other", file: "upstream/other.yaml"}.verify([]byte("hello"), sums), "no SHA-256 sum is pinned")
+
+	_, err = parseSums([]byte("not a sum line at all\n"))
+	assert.Error(t, err)
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("kind: " + r.URL.Path + "\n"))
+	}))
+	defer server.Close()
+	saved := All
+	defer func() { All = saved }()
+	All = []Manifest{{Name: "demo", URL: server.URL + "/demo", file: "upstream/demo.yaml"}}
+	dir := t.TempDir()
+	logf := func(string, ...interface{}) {}
+
+	assert.ErrorContains(t, Fetch(context.Background(), dir, false, logf), "no SHA-256 sum is pinned")
+	assert.NoError(t, Fetch(context.Background(), dir, true, logf))
+	data, err := os.ReadFile(filepath.Join(dir, "SHA256SUMS"))
+	assert.NoError(t, err)
+	assert.Regexp(t, `^[0-9a-f]{64}  demo\.yaml\n$`, string(data))
+	assert.NoError(t, Fetch(context.Background(), dir, false, logf))
+
+	All[0].URL = server.URL + "/changed"
+	assert.ErrorContains(t, Fetch(context.Background(), dir, false, logf), "demo manifest has SHA-256")
+}
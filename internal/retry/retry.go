@@ -0,0 +1,107 @@
+// Package retry repeats operations that fail with transient errors, such as
+// API server or webhook hiccups on a freshly created cluster.
+package retry
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Policy controls how often and how fast failed operations are retried.
+type Policy struct {
+	// Attempts is the total number of tries; values below two disable
+	// retries.
+	Attempts int
+	// Backoff is the delay before the first retry. It doubles with every
+	// retry up to MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// OnRetry, if set, is called before waiting for the next attempt.
+	OnRetry func(err error, attempt int, delay time.Duration)
+}
+
+// DefaultPolicy is used when the context carries no policy.
+var DefaultPolicy = Policy{Attempts: 4, Backoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+type policyKey struct{}
+
+// WithPolicy returns a context whose operations are retried with p.
+func WithPolicy(ctx context.Context, p Policy) context.Context {
+	return context.WithValue(ctx, policyKey{}, p)
+}
+
+// FromContext returns the policy of ctx, or DefaultPolicy.
+func FromContext(ctx context.Context) Policy {
+	if p, ok := ctx.Value(policyKey{}).(Policy); ok {
+		return p
+	}
+	return DefaultPolicy
+}
+
+// Do calls fn until it succeeds, fails with an error that is not transient,
+// the attempts of the context's policy are used up or ctx is done.
+func Do(ctx context.Context, fn func() error) error {
+	p := FromContext(ctx)
+	delay := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Attempts || ctx.Err() != nil || !Transient(err) {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(err, attempt, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+		if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+			delay = p.MaxBackoff
+		}
+	}
+}
+
+// transientPatterns are fragments of error messages from kubectl, helm,
+// kind, docker and Go's HTTP client that indicate a temporary condition.
+var transientPatterns = []string{
+	// Admission webhooks whose pods are not ready yet, e.g. ingress-nginx.
+	"failed calling webhook",
+	"no endpoints available for service",
+	// API server or registry not reachable yet or overloaded.
+	"connection refused",
+	"connection reset by peer",
+	"tls handshake timeout",
+	"i/o timeout",
+	"unexpected eof",
+	"the server is currently unable to handle the request",
+	"etcdserver: request timed out",
+	"etcdserver: leader changed",
+	"too many requests",
+	"serviceunavailable",
+	"service unavailable",
+	"bad gateway",
+	"gateway timeout",
+	"temporary failure in name resolution",
+	"server misbehaving",
+}
+
+// Transient reports whether err looks like a temporary failure that may
+// succeed when tried again. Cancellation and deadlines never are.
+func Transient(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, pattern := range transientPatterns {
+		if strings.Contains(msg, pattern) {
+			return true
+		}
+	}
+	return false
+}
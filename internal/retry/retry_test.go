@@ -0,0 +1,53 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	var retries []int
+	ctx := WithPolicy(context.Background(), Policy{
+		Attempts: 3,
+		Backoff:  time.Millisecond,
+		OnRetry:  func(err error, attempt int, delay time.Duration) { retries = append(retries, attempt) },
+	})
+
+	calls := 0
+	err := Do(ctx, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New(`Internal error occurred: failed calling webhook "validate.nginx.ingress.kubernetes.io"`)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Equal(t, []int{1, 2}, retries)
+
+	calls = 0
+	err = Do(ctx, func() error {
+		calls++
+		return errors.New("dial tcp 127.0.0.1:6443: connect: connection refused")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	calls = 0
+	err = Do(ctx, func() error {
+		calls++
+		return errors.New(`deployments.apps "adminer" is invalid`)
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
+
+func TestTransient(t *testing.T) {
+	assert.True(t, Transient(errors.New("net/http: TLS handshake timeout")))
+	assert.False(t, Transient(context.DeadlineExceeded))
+	assert.False(t, Transient(errors.New("Error: INSTALLATION FAILED: chart not found")))
+}
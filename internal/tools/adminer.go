@@ -1,23 +1,21 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
 
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
 )
 
-// InstallAdminer installs Adminer and sets up ingress.
-func InstallAdminer(log *logger.Logger, cfg *config.Config) error {
-	// Apply Adminer manifest
-	manifest := `
+// adminerTemplate deploys Adminer and its Service.
+var adminerTemplate = manifestTemplate("adminer", `
 apiVersion: apps/v1
 kind: Deployment
 metadata:
   name: adminer
   namespace: default
 spec:
+  replicas: {{ .Replicas }}
   selector:
     matchLabels:
       app: adminer
@@ -31,6 +29,10 @@ spec:
         image: adminer:4.8.1
         ports:
         - containerPort: 8080
+{{- with .Resources }}
+        resources:
+{{ yaml 10 . }}
+{{- end }}
 ---
 apiVersion: v1
 kind: Service
@@ -43,15 +45,30 @@ spec:
   ports:
   - port: 80
     targetPort: 8080
-`
-	if err := os.WriteFile("adminer.yaml", []byte(manifest), 0644); err != nil {
+`)
+
+// adminerManifest renders the Adminer manifest with the configured replicas
+// and resources.
+func adminerManifest(cfg *config.Config) (string, error) {
+	return renderTemplate(adminerTemplate, newWorkloadData(cfg.Adminer.Workload, config.Persistence{}, ""))
+}
+
+// renderAdminer returns the Adminer manifest.
+func renderAdminer(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]byte, error) {
+	manifest, err := adminerManifest(cfg)
+	return []byte(manifest), err
+}
+
+// InstallAdminer installs Adminer and sets up ingress.
+func InstallAdminer(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	// Apply Adminer manifest
+	manifest, err := adminerManifest(cfg)
+	if err != nil {
 		return err
 	}
-	cmd := exec.Command("kubectl", "apply", "-f", "adminer.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, manifest); err != nil {
 		return err
 	}
-	_ = os.Remove("adminer.yaml")
 
 	// Apply ingress
 	ingressManifest := `
@@ -73,14 +90,9 @@ spec:
             port:
               number: 80
 `
-	if err := os.WriteFile("adminer-ingress.yaml", []byte(ingressManifest), 0644); err != nil {
-		return err
-	}
-	cmd = exec.Command("kubectl", "apply", "-f", "adminer-ingress.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, ingressManifest); err != nil {
 		return err
 	}
-	_ = os.Remove("adminer-ingress.yaml")
 
 	log.Info("Installed Adminer with ingress: %s", cfg.Adminer.Ingress)
 	return nil
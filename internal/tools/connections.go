@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+
+	"kindctl/internal/config"
+)
+
+// Connections returns how workloads inside the cluster reach the enabled
+// tools, keyed by tool name and then by host, port, url and credentials.
+func Connections(cfg *config.Config) map[string]map[string]string {
+	conns := map[string]map[string]string{}
+	if cfg.Postgres.Enabled {
+		host := "postgres-postgresql.default.svc.cluster.local"
+		conns["postgres"] = map[string]string{
+			"host":     host,
+			"port":     "5432",
+			"username": cfg.Postgres.Username,
+			"password": cfg.Postgres.Password,
+			"database": cfg.Postgres.Database,
+			"url": (&url.URL{
+				Scheme: "postgres",
+				User:   url.UserPassword(cfg.Postgres.Username, cfg.Postgres.Password),
+				Host:   host + ":5432",
+				Path:   "/" + cfg.Postgres.Database,
+			}).String(),
+		}
+	}
+	if cfg.Redis.Enabled {
+		host := "redis-master.default.svc.cluster.local"
+		u := &url.URL{Scheme: "redis", Host: host + ":6379"}
+		conns["redis"] = map[string]string{
+			"host": host,
+			"port": "6379",
+		}
+		if cfg.Redis.Password != "" {
+			u.User = url.UserPassword("default", cfg.Redis.Password)
+			conns["redis"]["username"] = "default"
+			conns["redis"]["password"] = cfg.Redis.Password
+		}
+		conns["redis"]["url"] = u.String()
+	}
+	if cfg.RabbitMQ.Enabled {
+		host := "rabbitmq.default.svc.cluster.local"
+		conns["rabbitmq"] = map[string]string{
+			"host":     host,
+			"port":     "5672",
+			"username": cfg.RabbitMQ.Username,
+			"password": cfg.RabbitMQ.Password,
+			"url": (&url.URL{
+				Scheme: "amqp",
+				User:   url.UserPassword(cfg.RabbitMQ.Username, cfg.RabbitMQ.Password),
+				Host:   host + ":5672",
+				Path:   "/",
+			}).String(),
+		}
+	}
+	if cfg.Mailpit.Enabled {
+		host := "mailpit.default.svc.cluster.local"
+		conns["mailpit"] = map[string]string{
+			"host": host,
+			"port": "1025",
+			"url":  fmt.Sprintf("smtp://%s:1025", host),
+		}
+	}
+	return conns
+}
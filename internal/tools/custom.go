@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"kindctl/internal/config"
+	"kindctl/internal/helm"
+	"kindctl/internal/kube"
+	"kindctl/internal/logger"
+	"kindctl/internal/manifests"
+)
+
+// ReadManifests reads a manifest file, or the YAML files of a directory in
+// name order.
+func ReadManifests(path string) ([]byte, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return os.ReadFile(path)
+	}
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		matches, err := filepath.Glob(filepath.Join(path, pattern))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	var buf bytes.Buffer
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("---\n")
+		buf.Write(data)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes(), nil
+}
+
+// customTool adapts a tool declared in the configuration to the registry.
+func customTool(ct config.CustomTool) tool {
+	return tool{
+		name:    ct.Name,
+		deps:    ct.DependsOn,
+		enabled: func(*config.Config) bool { return ct.Enabled },
+		ingress: func(*config.Config) string { return ct.Ingress.Host },
+		install: func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+			return installCustom(ctx, log, cfg, opts, ct)
+		},
+		remove: func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+			return removeCustom(ctx, log, cfg, opts, ct)
+		},
+		render: func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]byte, error) {
+			return renderCustom(ctx, log, cfg, opts, ct)
+		},
+	}
+}
+
+// validateCustom checks that a custom tool has exactly one source.
+func validateCustom(ct config.CustomTool) error {
+	if ct.Name == "" {
+		return fmt.Errorf("custom tool without a name")
+	}
+	hasChart, hasManifests := ct.Chart.Name != "", ct.Manifests != ""
+	if hasChart == hasManifests {
+		return fmt.Errorf("custom tool %s needs either chart.name or manifests", ct.Name)
+	}
+	if !hasChart && (len(ct.Values) > 0 || ct.ValuesFile != "") {
+		return fmt.Errorf("custom tool %s sets values without a chart", ct.Name)
+	}
+	return nil
+}
+
+func customNamespace(ct config.CustomTool) string {
+	if ct.Namespace != "" {
+		return ct.Namespace
+	}
+	return "default"
+}
+
+// customRemoteChart returns the chart of a custom tool that installs one
+// from a repository or an OCI registry. Archives are cached per tool.
+func customRemoteChart(ct config.CustomTool) (chart, bool) {
+	switch {
+	case ct.Chart.Repo != "":
+		return chart{Repo: ct.Name, RepoURL: ct.Chart.Repo, Name: ct.Chart.Name, Version: ct.Chart.Version}, true
+	case strings.HasPrefix(ct.Chart.Name, "oci://"):
+		return chart{Repo: ct.Name, Name: ct.Chart.Name, Version: ct.Chart.Version}, true
+	}
+	return chart{}, false
+}
+
+// customChart loads the chart of a chart-based custom tool and returns the
+// release to install, with the values file merged with the inline values.
+func customChart(ctx context.Context, log *logger.Logger, client *helm.Client, cfg *config.Config, opts UpdateOptions, ct config.CustomTool) (helm.Release, error) {
+	rel := helm.Release{Name: ct.Name, Namespace: customNamespace(ct)}
+	var err error
+	if c, ok := customRemoteChart(ct); ok {
+		rel.Chart, err = lockedChart(ctx, log, client, opts, ct.Name, c)
+	} else {
+		rel.Chart, err = client.Load(ctx, cfg.Path(ct.Chart.Name), "")
+	}
+	if err != nil {
+		return rel, err
+	}
+	rel.Values = map[string]interface{}{}
+	if ct.ValuesFile != "" {
+		rel.Values, err = helm.ReadValuesFile(cfg.Path(ct.ValuesFile))
+		if err != nil {
+			return rel, err
+		}
+	}
+	rel.Values = helm.MergeValues(rel.Values, ct.Values)
+	return rel, nil
+}
+
+// customManifests reads the manifests of a manifest-based custom tool from
+// a file, a directory or a URL.
+func customManifests(ctx context.Context, cfg *config.Config, opts UpdateOptions, ct config.CustomTool) ([]byte, error) {
+	if strings.HasPrefix(ct.Manifests, "http://") || strings.HasPrefix(ct.Manifests, "https://") {
+		if opts.Offline {
+			return nil, fmt.Errorf("manifests of %s are downloaded from %s and --offline is set", ct.Name, ct.Manifests)
+		}
+		return manifests.Download(ctx, ct.Manifests)
+	}
+	return ReadManifests(cfg.Path(ct.Manifests))
+}
+
+// renderCustom returns the manifests a custom tool installs.
+func renderCustom(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, ct config.CustomTool) ([]byte, error) {
+	if ct.Manifests != "" {
+		return customManifests(ctx, cfg, opts, ct)
+	}
+	client, err := newHelm(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := customChart(ctx, log, client, cfg, opts, ct)
+	if err != nil {
+		return nil, err
+	}
+	return client.Template(ctx, rel)
+}
+
+// installCustom installs or upgrades a custom tool and applies its ingress.
+func installCustom(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, ct config.CustomTool) error {
+	if ct.Manifests != "" {
+		manifest, err := customManifests(ctx, cfg, opts, ct)
+		if err != nil {
+			return err
+		}
+		if err := applyManifest(ctx, cfg, opts, string(manifest)); err != nil {
+			return err
+		}
+		client, err := kube.ForCluster(cfg.Cluster.Name)
+		if err != nil {
+			return err
+		}
+		if err := client.SaveInventory(ctx, customNamespace(ct), ct.Name, manifest); err != nil {
+			return err
+		}
+	} else {
+		client, err := newHelm(cfg, log)
+		if err != nil {
+			return err
+		}
+		rel, err := customChart(ctx, log, client, cfg, opts, ct)
+		if err != nil {
+			return err
+		}
+		if err := helmUpgrade(ctx, client, opts, rel); err != nil {
+			return err
+		}
+	}
+	if ct.Ingress.Host != "" {
+		if err := applyManifest(ctx, cfg, opts, customIngress(ct)); err != nil {
+			return err
+		}
+	}
+	log.Infof("Installed %s with ingress: %s", ct.Name, ct.Ingress.Host)
+	return nil
+}
+
+// customIngress routes the tool's ingress host to its Service.
+func customIngress(ct config.CustomTool) string {
+	service, port := ct.Ingress.Service, ct.Ingress.Port
+	if service == "" {
+		service = ct.Name
+	}
+	if port == 0 {
+		port = 80
+	}
+	return `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: ` + ct.Name + `-ingress
+  namespace: ` + customNamespace(ct) + `
+spec:
+  rules:
+  - host: ` + ct.Ingress.Host + `
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: ` + service + `
+            port:
+              number: ` + strconv.Itoa(port) + `
+`
+}
+
+// removeCustom prunes a disabled custom tool if it is still installed.
+func removeCustom(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, ct config.CustomTool) error {
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
+		return err
+	}
+	uninstall := func() (bool, error) {
+		helmClient, err := newHelm(cfg, log)
+		if err != nil {
+			return false, err
+		}
+		return helmClient.Uninstall(ctx, ct.Name, customNamespace(ct))
+	}
+	return pruneCustom(ctx, log, client, uninstall, opts, ct)
+}
+
+// pruneCustom removes the Helm release of a chart tool with uninstall, or
+// the objects recorded in the inventory of a manifest tool, and the ingress
+// if the tool has a host. Neither check needs the chart or the manifests.
+func pruneCustom(ctx context.Context, log *logger.Logger, client *kube.Client, uninstall func() (bool, error), opts UpdateOptions, ct config.CustomTool) error {
+	var removed bool
+	var err error
+	if ct.Manifests != "" {
+		removed, err = client.DeleteInventory(ctx, customNamespace(ct), ct.Name, opts.stdout())
+	} else {
+		removed, err = uninstall()
+	}
+	if err != nil {
+		return err
+	}
+	if ct.Ingress.Host != "" {
+		deleted, err := client.Delete(ctx, []byte(customIngress(ct)), opts.stdout())
+		if err != nil {
+			return err
+		}
+		removed = removed || deleted > 0
+	}
+	if removed {
+		log.Infof("Removed disabled tool %s", ct.Name)
+	}
+	return nil
+}
+
+// allTools returns the built-in tools followed by the custom tools of the
+// configuration.
+func allTools(cfg *config.Config) ([]tool, error) {
+	all := append([]tool{}, registry...)
+	for _, ct := range cfg.Custom {
+		if err := validateCustom(ct); err != nil {
+			return nil, err
+		}
+		for _, t := range all {
+			if t.name == ct.Name {
+				return nil, fmt.Errorf("custom tool %q clashes with another tool", ct.Name)
+			}
+		}
+		all = append(all, customTool(ct))
+	}
+	return all, nil
+}
@@ -1,22 +1,136 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
+	"fmt"
+	"time"
 
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 	"kindctl/internal/config"
+	"kindctl/internal/kube"
 	"kindctl/internal/logger"
+	"kindctl/internal/manifests"
+	"kindctl/internal/retry"
 )
 
-// InstallDashboard installs the Kubernetes Dashboard.
-func InstallDashboard(log *logger.Logger, cfg *config.Config) error {
-	// Apply Kubernetes Dashboard manifests (simplified example)
-	cmd := exec.Command("kubectl", "apply", "-f", "https://raw.githubusercontent.com/kubernetes/dashboard/v2.7.0/aio/deploy/recommended.yaml")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+const (
+	dashboardNamespace = "kubernetes-dashboard"
+	dashboardAdminUser = "admin-user"
+)
+
+// InstallDashboard installs the Kubernetes Dashboard and exposes it on the
+// configured ingress host.
+func InstallDashboard(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	manifest, err := renderDashboard(ctx, log, cfg, opts)
+	if err != nil {
+		return err
+	}
+	if err := applyManifest(ctx, cfg, opts, string(manifest)); err != nil {
+		return err
+	}
+	if err := applyManifest(ctx, cfg, opts, dashboardIngress(cfg)); err != nil {
 		return err
 	}
-	log.Info("Installed Kubernetes Dashboard")
+
+	if cfg.Dashboard.AdminUser {
+		if err := applyManifest(ctx, cfg, opts, dashboardAdmin); err != nil {
+			return err
+		}
+		log.Infof("Created dashboard ServiceAccount %s, get a login token with: kindctl dashboard token", dashboardAdminUser)
+	}
+
+	log.Infof("Installed Kubernetes Dashboard with ingress: https://%s", cfg.Dashboard.Ingress)
 	return nil
 }
+
+// dashboardIngress routes the configured host to the dashboard. The
+// dashboard only serves HTTPS, so the controller has to talk TLS to it.
+func dashboardIngress(cfg *config.Config) string {
+	return `
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: dashboard-ingress
+  namespace: ` + dashboardNamespace + `
+  annotations:
+    nginx.ingress.kubernetes.io/backend-protocol: "HTTPS"
+spec:
+  rules:
+  - host: ` + cfg.Dashboard.Ingress + `
+    http:
+      paths:
+      - path: /
+        pathType: Prefix
+        backend:
+          service:
+            name: kubernetes-dashboard
+            port:
+              number: 443
+`
+}
+
+// dashboardAdmin is the cluster-admin ServiceAccount created with
+// dashboard.adminUser.
+const dashboardAdmin = `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: ` + dashboardAdminUser + `
+  namespace: ` + dashboardNamespace + `
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: kubernetes-dashboard-` + dashboardAdminUser + `
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: cluster-admin
+subjects:
+- kind: ServiceAccount
+  name: ` + dashboardAdminUser + `
+  namespace: ` + dashboardNamespace + `
+`
+
+// renderDashboard returns the dashboard manifest with the configured
+// replicas and resources applied to the dashboard itself, not to its
+// metrics scraper.
+func renderDashboard(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]byte, error) {
+	manifest, err := manifests.Dashboard.Load(ctx, opts.Offline)
+	if err != nil {
+		return nil, err
+	}
+	return sizeDeployment(manifest, "kubernetes-dashboard", cfg.Dashboard.Workload)
+}
+
+// DashboardToken mints a login token for the dashboard admin ServiceAccount.
+func DashboardToken(ctx context.Context, cfg *config.Config, duration time.Duration) (string, error) {
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
+		return "", err
+	}
+	return dashboardToken(ctx, client.Clientset, duration)
+}
+
+// dashboardToken requests a token for the admin ServiceAccount through the
+// TokenRequest API.
+func dashboardToken(ctx context.Context, clientset kubernetes.Interface, duration time.Duration) (string, error) {
+	seconds := int64(duration.Seconds())
+	request := &authenticationv1.TokenRequest{Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds}}
+	var token *authenticationv1.TokenRequest
+	err := retry.Do(ctx, func() error {
+		var err error
+		token, err = clientset.CoreV1().ServiceAccounts(dashboardNamespace).CreateToken(ctx, dashboardAdminUser, request, metav1.CreateOptions{})
+		return err
+	})
+	if apierrors.IsNotFound(err) {
+		return "", fmt.Errorf("ServiceAccount %s/%s not found: set dashboard.adminUser to true and run kindctl update", dashboardNamespace, dashboardAdminUser)
+	}
+	if err != nil {
+		return "", err
+	}
+	return token.Status.Token, nil
+}
@@ -0,0 +1,225 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"kindctl/internal/logger"
+)
+
+// graph orders the enabled tools by their dependencies.
+type graph struct {
+	tools []tool
+	// deps maps each tool to the enabled tools it waits for.
+	deps map[string][]string
+}
+
+// newGraph builds the dependency graph of the enabled tools. Dependencies on
+// disabled tools are ignored; unknown dependencies and cycles are errors.
+func newGraph(all, enabled []tool) (*graph, error) {
+	known := map[string]bool{}
+	for _, t := range all {
+		known[t.name] = true
+	}
+	isEnabled := map[string]bool{}
+	for _, t := range enabled {
+		isEnabled[t.name] = true
+	}
+	g := &graph{tools: enabled, deps: map[string][]string{}}
+	for _, t := range enabled {
+		for _, dep := range t.deps {
+			if !known[dep] {
+				return nil, fmt.Errorf("%s depends on unknown tool %q", t.name, dep)
+			}
+			if isEnabled[dep] {
+				g.deps[t.name] = append(g.deps[t.name], dep)
+			}
+		}
+	}
+	if cycle := g.cycle(); cycle != nil {
+		return nil, fmt.Errorf("dependency cycle between tools: %s", strings.Join(cycle, " -> "))
+	}
+	return g, nil
+}
+
+// cycle returns the tools of a dependency cycle, or nil.
+func (g *graph) cycle() []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		switch state[name] {
+		case visiting:
+			for i, n := range path {
+				if n == name {
+					return append(append([]string{}, path[i:]...), name)
+				}
+			}
+		case done:
+			return nil
+		}
+		state[name] = visiting
+		path = append(path, name)
+		for _, dep := range g.deps[name] {
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+	for _, t := range g.tools {
+		if cycle := visit(t.name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// result is the outcome of installing one tool.
+type result struct {
+	tool     tool
+	status   Status
+	err      error
+	duration time.Duration
+}
+
+// run installs the tools, starting each once its dependencies are installed
+// and running up to opts.Parallel installs at a time. Ready tools start in
+// registry order. done is called from the calling goroutine for every
+// installed tool and may turn it into a failure. After a failure no further
+// tools are started unless opts.ContinueOnError is set, in which case only
+// the tools depending on the failed one are skipped. Each install runs with
+// a deadline of opts.StepTimeout, and no tools are started once ctx is done.
+// run returns the outcome of every tool in registry order and the first
+// error.
+func (g *graph) run(ctx context.Context, log *logger.Logger, opts UpdateOptions, install func(context.Context, *logger.Logger, tool, UpdateOptions) error, done func(tool) error) (Summary, error) {
+	parallel := opts.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	width := 0
+	for _, t := range g.tools {
+		if len(t.name) > width {
+			width = len(t.name)
+		}
+	}
+	var mu sync.Mutex
+
+	outcomes := map[string]ToolResult{}
+	results := make(chan result)
+	running := 0
+	var firstErr error
+
+	// blocked returns the failed dependency of a tool, or "".
+	blocked := func(t tool) string {
+		for _, dep := range g.deps[t.name] {
+			if status := outcomes[dep].Status; status == StatusFailed || status == StatusSkipped {
+				return dep
+			}
+		}
+		return ""
+	}
+	ready := func(t tool) bool {
+		for _, dep := range g.deps[t.name] {
+			if _, ok := outcomes[dep]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+	started := map[string]bool{}
+	start := func(t tool) {
+		started[t.name] = true
+		running++
+		toolLog, toolOpts := log, opts
+		toolOpts.tool, toolOpts.changes = t.name, &changes{}
+		var stdout, stderr *prefixWriter
+		if parallel > 1 {
+			prefix := fmt.Sprintf("[%-*s] ", width, t.name)
+			stdout = newPrefixWriter(&mu, opts.stdout(), prefix)
+			stderr = newPrefixWriter(&mu, opts.stderr(), prefix)
+			toolLog = log.To(stdout)
+			toolOpts.Stdout, toolOpts.Stderr = stdout, stderr
+		}
+		go func() {
+			toolCtx, cancel := ctx, context.CancelFunc(func() {})
+			if opts.StepTimeout > 0 {
+				toolCtx, cancel = context.WithTimeout(ctx, opts.StepTimeout)
+			}
+			defer cancel()
+			begin := time.Now()
+			err := install(toolCtx, toolLog, t, toolOpts)
+			if toolCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+				err = fmt.Errorf("timed out after %s: %w", opts.StepTimeout, err)
+			}
+			if stdout != nil {
+				_ = stdout.Flush()
+				_ = stderr.Flush()
+			}
+			results <- result{tool: t, status: toolOpts.changes.status(), err: err, duration: time.Since(begin)}
+		}()
+	}
+
+	for {
+		progress := true
+		for progress && ctx.Err() == nil && (firstErr == nil || opts.ContinueOnError) {
+			progress = false
+			for _, t := range g.tools {
+				if started[t.name] || !ready(t) {
+					continue
+				}
+				if dep := blocked(t); dep != "" {
+					started[t.name] = true
+					outcomes[t.name] = ToolResult{Tool: t.name, Status: StatusSkipped, Reason: dep + " failed"}
+					progress = true
+					continue
+				}
+				if running < parallel {
+					start(t)
+				}
+			}
+		}
+		if running == 0 {
+			break
+		}
+		r := <-results
+		running--
+		outcome := ToolResult{Tool: r.tool.name, Status: r.status, Duration: r.duration}
+		err := r.err
+		if err == nil {
+			err = done(r.tool)
+		}
+		if err != nil {
+			outcome.Status, outcome.Reason = StatusFailed, err.Error()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("installing %s: %w", r.tool.name, err)
+			}
+		}
+		outcomes[r.tool.name] = outcome
+	}
+
+	summary := make(Summary, 0, len(g.tools))
+	for _, t := range g.tools {
+		outcome, ok := outcomes[t.name]
+		if !ok {
+			outcome = ToolResult{Tool: t.name, Status: StatusSkipped, Reason: "not attempted after an earlier failure"}
+			if ctx.Err() != nil {
+				outcome.Reason = "cancelled"
+			}
+		}
+		summary = append(summary, outcome)
+	}
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	return summary, firstErr
+}
@@ -0,0 +1,348 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	helmchart "helm.sh/helm/v3/pkg/chart"
+	"kindctl/internal/config"
+	"kindctl/internal/helm"
+	"kindctl/internal/logger"
+)
+
+// chart is a Helm chart installed by a built-in or custom tool.
+type chart struct {
+	// Repo names the repository and the cache directory.
+	Repo    string
+	RepoURL string
+	// Name is the chart in the repository, or an oci:// reference without
+	// RepoURL.
+	Name string
+	// Version pins the chart; empty means the latest version.
+	Version string
+}
+
+const (
+	bitnamiRepo = "https://charts.bitnami.com/bitnami"
+	runixRepo   = "https://helm.runix.net"
+)
+
+// toolCharts maps the chart-based tools to their charts.
+var toolCharts = map[string]chart{
+	"postgres": {Repo: "bitnami", RepoURL: bitnamiRepo, Name: "postgresql"},
+	"redis":    {Repo: "bitnami", RepoURL: bitnamiRepo, Name: "redis"},
+	"rabbitmq": {Repo: "bitnami", RepoURL: bitnamiRepo, Name: "rabbitmq"},
+	"pgadmin":  {Repo: "runix", RepoURL: runixRepo, Name: "pgadmin4"},
+}
+
+// chartSettings returns the chart settings of a chart-based tool.
+func chartSettings(cfg *config.Config, name string) config.ChartSettings {
+	switch name {
+	case "postgres":
+		return cfg.Postgres.ChartSettings
+	case "redis":
+		return cfg.Redis.ChartSettings
+	case "rabbitmq":
+		return cfg.RabbitMQ.ChartSettings
+	case "pgadmin":
+		return cfg.PgAdmin.ChartSettings
+	}
+	return config.ChartSettings{}
+}
+
+// toolChart returns the chart of a chart-based tool with the version pinned
+// in the configuration.
+func toolChart(cfg *config.Config, name string) chart {
+	c := toolCharts[name]
+	c.Version = chartSettings(cfg, name).ChartVersion
+	return c
+}
+
+// toolValues deep-merges the values files and then the inline values of a
+// chart-based tool over the values kindctl generates for it.
+func toolValues(cfg *config.Config, name string, generated map[string]interface{}) (map[string]interface{}, error) {
+	settings := chartSettings(cfg, name)
+	values := generated
+	for _, file := range settings.ValuesFiles {
+		override, err := helm.ReadValuesFile(cfg.Path(file))
+		if err != nil {
+			return nil, fmt.Errorf("reading values file of %s: %w", name, err)
+		}
+		values = helm.MergeValues(values, override)
+	}
+	return helm.MergeValues(values, settings.Values), nil
+}
+
+// ChartTools returns the tools that are installed from a remote Helm
+// chart: the chart-based built-in tools and the custom tools with a
+// repository or OCI chart.
+func ChartTools(cfg *config.Config) ([]string, error) {
+	all, err := allTools(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, t := range all {
+		if _, ok := remoteChart(cfg, t.name); ok {
+			names = append(names, t.name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// remoteChart returns the chart of a built-in or custom tool with the
+// version pinned in the configuration, if the tool installs one from a
+// repository or an OCI registry.
+func remoteChart(cfg *config.Config, name string) (chart, bool) {
+	if _, ok := toolCharts[name]; ok {
+		return toolChart(cfg, name), true
+	}
+	for _, ct := range cfg.Custom {
+		if ct.Name == name {
+			return customRemoteChart(ct)
+		}
+	}
+	return chart{}, false
+}
+
+// Ref returns the chart reference in repo/name form, or the OCI reference.
+func (c chart) Ref() string {
+	if c.oci() {
+		return c.Name
+	}
+	return c.Repo + "/" + c.Name
+}
+
+// oci reports whether the chart is pulled from an OCI registry.
+func (c chart) oci() bool {
+	return strings.HasPrefix(c.Name, "oci://")
+}
+
+// archive returns the name archives of the chart start with, e.g. grafana
+// for oci://ghcr.io/grafana/helm-charts/grafana.
+func (c chart) archive() string {
+	return path.Base(c.Name)
+}
+
+// cacheDir returns the directory cached archives of the chart are kept in.
+func (c chart) cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "kindctl", "charts", c.Repo), nil
+}
+
+// cached returns the path of the newest cached archive matching the chart,
+// or an empty string if none is cached.
+func (c chart) cached() (string, error) {
+	dir, err := c.cacheDir()
+	if err != nil {
+		return "", err
+	}
+	if c.Version != "" {
+		archive := filepath.Join(dir, c.archive()+"-"+c.Version+".tgz")
+		if _, err := os.Stat(archive); err != nil {
+			return "", nil
+		}
+		return archive, nil
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, c.archive()+"-*.tgz"))
+	if err != nil {
+		return "", err
+	}
+	newest, newestVersion := "", ""
+	for _, match := range matches {
+		version := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(match), c.archive()+"-"), ".tgz")
+		// Skip archives of other charts sharing the prefix, e.g. postgresql-ha.
+		if version == "" || version[0] < '0' || version[0] > '9' {
+			continue
+		}
+		if newest == "" || compareVersions(version, newestVersion) > 0 {
+			newest, newestVersion = match, version
+		}
+	}
+	return newest, nil
+}
+
+// compareVersions compares two dotted version strings numerically.
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		switch {
+		case aerr != nil || berr != nil:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		case an != bn:
+			if an < bn {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// newHelm returns a Helm client for the cluster that logs to log at debug
+// level.
+func newHelm(cfg *config.Config, log *logger.Logger) (*helm.Client, error) {
+	return helm.New(cfg.Cluster.Name, log.Debugf)
+}
+
+// ensureRepo adds the chart's repository to kindctl's own repository file
+// and downloads its index, once per run. OCI charts have no repository.
+func ensureRepo(ctx context.Context, log *logger.Logger, client *helm.Client, c chart) error {
+	if c.oci() {
+		return nil
+	}
+	fetched, err := client.AddRepo(ctx, c.Repo, c.RepoURL)
+	if err != nil {
+		return err
+	}
+	if fetched {
+		log.Infof("Updated the index of the %s Helm repository", c.Repo)
+	}
+	return nil
+}
+
+// loadChart loads the chart, preferring a cached archive over the remote
+// repository unless the lockfile is being refreshed.
+func loadChart(ctx context.Context, log *logger.Logger, client *helm.Client, opts UpdateOptions, c chart) (*helmchart.Chart, error) {
+	ref, err := c.cached()
+	if err != nil {
+		return nil, err
+	}
+	if ref != "" && !opts.lock.refreshing() {
+		log.Debugf("Using cached chart %s", ref)
+		return client.Load(ctx, ref, "")
+	}
+	if opts.Offline {
+		return nil, fmt.Errorf("chart %s is not cached; run kindctl cache pull before using --offline", c.Ref())
+	}
+	if err := ensureRepo(ctx, log, client, c); err != nil {
+		return nil, err
+	}
+	return client.Load(ctx, c.Ref(), c.Version)
+}
+
+// lockedChart loads the chart of a tool in the version of the lockfile,
+// unless the configuration pins one, and records the version it loaded.
+func lockedChart(ctx context.Context, log *logger.Logger, client *helm.Client, opts UpdateOptions, tool string, c chart) (*helmchart.Chart, error) {
+	if c.Version == "" {
+		c.Version = opts.lock.chartVersion(tool, c.Ref())
+	}
+	loaded, err := loadChart(ctx, log, client, opts, c)
+	if err != nil {
+		return nil, err
+	}
+	opts.lock.recordChart(tool, c.Ref(), loaded.Metadata.Version)
+	return loaded, nil
+}
+
+// helmInstall installs or upgrades the release of a chart-based tool in the
+// default namespace, with the values of the configuration merged over the
+// generated ones.
+func helmInstall(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, release string, values map[string]interface{}) error {
+	values, err := toolValues(cfg, release, values)
+	if err != nil {
+		return err
+	}
+	client, err := newHelm(cfg, log)
+	if err != nil {
+		return err
+	}
+	loaded, err := lockedChart(ctx, log, client, opts, release, toolChart(cfg, release))
+	if err != nil {
+		return err
+	}
+	return helmUpgrade(ctx, client, opts, helm.Release{Name: release, Namespace: "default", Chart: loaded, Values: values})
+}
+
+// helmUpgrade installs or upgrades a release of a loaded chart, with the
+// images of its manifests pinned to the digests of the lockfile.
+func helmUpgrade(ctx context.Context, client *helm.Client, opts UpdateOptions, rel helm.Release) error {
+	rel.PostRender = func(manifest []byte) ([]byte, error) {
+		return opts.lock.pin(ctx, opts.tool, manifest)
+	}
+	deployed, changed, err := client.Upgrade(ctx, rel)
+	if err != nil {
+		return err
+	}
+	opts.changes.recordRelease(deployed.Version, changed)
+	verb := "deployed to"
+	if !changed {
+		verb = "unchanged in"
+	}
+	fmt.Fprintf(opts.stdout(), "Release %s %s namespace %s (chart %s-%s, revision %d)\n",
+		deployed.Name, verb, deployed.Namespace, deployed.Chart.Metadata.Name, deployed.Chart.Metadata.Version, deployed.Version)
+	return nil
+}
+
+// helmTemplate renders the manifests the release of a chart-based tool would
+// install, with the values of the configuration merged over the generated
+// ones.
+func helmTemplate(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, release string, values map[string]interface{}) ([]byte, error) {
+	values, err := toolValues(cfg, release, values)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newHelm(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	loaded, err := lockedChart(ctx, log, client, opts, release, toolChart(cfg, release))
+	if err != nil {
+		return nil, err
+	}
+	return client.Template(ctx, helm.Release{Name: release, Namespace: "default", Chart: loaded, Values: values})
+}
+
+// PullCharts downloads the charts of the given tools, in the versions pinned
+// in the configuration or the lockfile, into the local cache.
+func PullCharts(ctx context.Context, log *logger.Logger, cfg *config.Config, tools []string) error {
+	client, err := helm.New("", log.Debugf)
+	if err != nil {
+		return err
+	}
+	state, err := openLock(log, cfg, UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	if _, err := allTools(cfg); err != nil {
+		return err
+	}
+	for _, tool := range tools {
+		c, ok := remoteChart(cfg, tool)
+		if !ok {
+			continue
+		}
+		if c.Version == "" {
+			c.Version = state.chartVersion(tool, c.Ref())
+		}
+		if err := ensureRepo(ctx, log, client, c); err != nil {
+			return err
+		}
+		dir, err := c.cacheDir()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := client.Pull(ctx, c.Ref(), c.Version, dir); err != nil {
+			return err
+		}
+		log.Infof("Cached chart %s for %s in %s", c.Ref(), tool, dir)
+	}
+	return nil
+}
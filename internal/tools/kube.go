@@ -0,0 +1,37 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"kindctl/internal/config"
+	"kindctl/internal/kube"
+)
+
+// applyManifest server-side applies a manifest to the cluster, with its
+// images pinned to the digests of the lockfile.
+func applyManifest(ctx context.Context, cfg *config.Config, opts UpdateOptions, manifest string) error {
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
+		return err
+	}
+	pinned, err := opts.lock.pin(ctx, opts.tool, []byte(manifest))
+	if err != nil {
+		return err
+	}
+	var stdout bytes.Buffer
+	err = client.Apply(ctx, pinned, io.MultiWriter(opts.stdout(), &stdout))
+	opts.changes.recordApply(stdout.Bytes())
+	return err
+}
+
+// deleteManifest deletes the objects of a manifest that exist and returns
+// how many it deleted.
+func deleteManifest(ctx context.Context, cfg *config.Config, opts UpdateOptions, manifest string) (int, error) {
+	client, err := kube.ForCluster(cfg.Cluster.Name)
+	if err != nil {
+		return 0, err
+	}
+	return client.Delete(ctx, []byte(manifest), opts.stdout())
+}
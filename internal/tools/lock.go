@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"kindctl/internal/config"
+	"kindctl/internal/images"
+	"kindctl/internal/lock"
+	"kindctl/internal/logger"
+)
+
+// lockState is the lockfile of one run. Tools read the versions they are
+// locked to from it and record the versions they resolve; a nil lockState
+// locks nothing.
+type lockState struct {
+	mu   sync.Mutex
+	log  *logger.Logger
+	file *lock.File
+	path string
+	// pinImages rewrites the images of the installed manifests to their
+	// digests. Images preloaded into the nodes are known by tag only, so
+	// they are not pinned offline or with cluster.preloadImages.
+	pinImages bool
+	// offline forbids resolving digests missing from the lockfile.
+	offline bool
+	// refresh resolves chart versions from the repositories instead of the
+	// chart cache.
+	refresh bool
+	changed bool
+}
+
+// openLock reads the lockfile next to the configuration file.
+func openLock(log *logger.Logger, cfg *config.Config, opts UpdateOptions) (*lockState, error) {
+	path := cfg.Path(lock.FileName)
+	file, err := lock.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &lockState{
+		log:       log,
+		file:      file,
+		path:      path,
+		pinImages: !opts.Offline && !cfg.Cluster.PreloadImages,
+		offline:   opts.Offline,
+	}, nil
+}
+
+// chartVersion returns the version of the chart ref the lockfile records
+// for a tool, or "" if it records none.
+func (l *lockState) chartVersion(tool, ref string) string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if entry, ok := l.file.Tools[tool]; ok && entry.Chart == ref {
+		return entry.Version
+	}
+	return ""
+}
+
+// recordChart records the chart version a tool resolved. A different
+// version drops the image digests recorded for the previous one.
+func (l *lockState) recordChart(tool, ref, version string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry := l.file.Tool(tool)
+	if entry.Chart == ref && entry.Version == version {
+		return
+	}
+	entry.Chart, entry.Version, entry.Images = ref, version, nil
+	l.changed = true
+}
+
+// refreshing reports whether charts are resolved from their repositories
+// rather than the chart cache.
+func (l *lockState) refreshing() bool {
+	return l != nil && l.refresh
+}
+
+// digests resolves the digests of the images of a tool, looking up the
+// ones missing from the lockfile unless offline. Images that cannot be
+// resolved are left out with a warning. The lookups run without holding
+// the lock, so tools installed in parallel do not wait on each other.
+func (l *lockState) digests(ctx context.Context, tool string, refs []string) map[string]string {
+	digests := map[string]string{}
+	var missing []string
+	l.mu.Lock()
+	entry := l.file.Tool(tool)
+	for _, ref := range refs {
+		if digest, ok := entry.Images[ref]; ok {
+			digests[ref] = digest
+		} else if !l.offline && !strings.Contains(ref, "@") {
+			missing = append(missing, ref)
+		}
+	}
+	l.mu.Unlock()
+
+	resolved := map[string]string{}
+	for _, ref := range missing {
+		digest, err := images.Digest(ctx, ref)
+		if err != nil {
+			l.log.Warnf("Not pinning image %s of %s: %v", ref, tool, err)
+			continue
+		}
+		resolved[ref] = digest
+	}
+	if len(resolved) == 0 {
+		return digests
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry = l.file.Tool(tool)
+	if entry.Images == nil {
+		entry.Images = map[string]string{}
+	}
+	for ref, digest := range resolved {
+		entry.Images[ref] = digest
+		digests[ref] = digest
+	}
+	l.changed = true
+	return digests
+}
+
+// locked returns the image digests recorded for a tool.
+func (l *lockState) locked(tool string) map[string]string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.file.Tools[tool]
+	if !ok || len(entry.Images) == 0 {
+		return nil
+	}
+	digests := make(map[string]string, len(entry.Images))
+	for ref, digest := range entry.Images {
+		digests[ref] = digest
+	}
+	return digests
+}
+
+// pin rewrites the images of a manifest a tool installs to the digests
+// locked for them.
+func (l *lockState) pin(ctx context.Context, tool string, manifest []byte) ([]byte, error) {
+	if l == nil || !l.pinImages || tool == "" {
+		return manifest, nil
+	}
+	refs, err := images.Extract(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return images.Pin(manifest, l.digests(ctx, tool, refs)), nil
+}
+
+// save writes the lockfile if the run changed it.
+func (l *lockState) save() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.changed {
+		return nil
+	}
+	if err := l.file.Save(l.path); err != nil {
+		return err
+	}
+	l.log.Infof("Updated %s", l.path)
+	l.changed = false
+	return nil
+}
+
+// UpdateLock re-resolves the chart versions and image digests of the named
+// tools, or of the enabled tools when names is empty, and writes them to
+// the lockfile without installing anything.
+func UpdateLock(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, names []string) error {
+	if opts.Offline {
+		return fmt.Errorf("updating the lockfile needs network access and --offline is set")
+	}
+	if len(names) == 0 {
+		var err error
+		if names, err = EnabledTools(cfg); err != nil {
+			return err
+		}
+	}
+	state, err := openLock(log, cfg, opts)
+	if err != nil {
+		return err
+	}
+	state.refresh = true
+	opts.lock = state
+	for _, name := range names {
+		t, err := lookupTool(cfg, name)
+		if err != nil {
+			return err
+		}
+		delete(state.file.Tools, name)
+		state.changed = true
+		opts.tool = name
+		refs, err := t.images(ctx, log, cfg, opts)
+		if err != nil {
+			return fmt.Errorf("resolving images of %s: %w", name, err)
+		}
+		digests := state.digests(ctx, name, refs)
+		entry := state.file.Tool(name)
+		if entry.Chart != "" {
+			log.Infof("Locked %s to chart %s %s", name, entry.Chart, entry.Version)
+		}
+		pinned := make([]string, 0, len(digests))
+		for ref := range digests {
+			pinned = append(pinned, ref)
+		}
+		sort.Strings(pinned)
+		for _, ref := range pinned {
+			log.Infof("Locked %s to image %s", name, images.Pinned(ref, digests[ref]))
+		}
+	}
+	return state.save()
+}
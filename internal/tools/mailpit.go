@@ -1,23 +1,38 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
 
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
 )
 
-// InstallMailpit installs Mailpit and sets up ingress.
-func InstallMailpit(log *logger.Logger, cfg *config.Config) error {
-	// Apply Mailpit manifest
-	manifest := `
+// mailpitTemplate deploys Mailpit and its Service, and a claim for its
+// database when persistence is enabled.
+var mailpitTemplate = manifestTemplate("mailpit", `
+{{- if .Persistence.Enabled }}
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: mailpit-data
+  namespace: default
+spec:
+  accessModes: [ReadWriteOnce]
+{{- with .Persistence.StorageClass }}
+  storageClassName: {{ . }}
+{{- end }}
+  resources:
+    requests:
+      storage: {{ .Persistence.Size }}
+---
+{{- end }}
 apiVersion: apps/v1
 kind: Deployment
 metadata:
   name: mailpit
   namespace: default
 spec:
+  replicas: {{ .Replicas }}
   selector:
     matchLabels:
       app: mailpit
@@ -28,14 +43,33 @@ spec:
     spec:
       containers:
       - name: mailpit
-        image: axllent/mailpit:latest
+        image: axllent/mailpit:v1.20.0
+        imagePullPolicy: IfNotPresent
         ports:
         - containerPort: 8025
+        - containerPort: 1025
         env:
         - name: MP_SMTP_AUTH_ACCEPT_ANY
           value: "1"
         - name: MP_SMTP_AUTH_ALLOW_INSECURE
           value: "1"
+{{- if .Persistence.Enabled }}
+        - name: MP_DATABASE
+          value: /data/mailpit.db
+        volumeMounts:
+        - name: data
+          mountPath: /data
+{{- end }}
+{{- with .Resources }}
+        resources:
+{{ yaml 10 . }}
+{{- end }}
+{{- if .Persistence.Enabled }}
+      volumes:
+      - name: data
+        persistentVolumeClaim:
+          claimName: mailpit-data
+{{- end }}
 ---
 apiVersion: v1
 kind: Service
@@ -46,17 +80,36 @@ spec:
   selector:
     app: mailpit
   ports:
-  - port: 80
+  - name: http
+    port: 80
     targetPort: 8025
-`
-	if err := os.WriteFile("mailpit.yaml", []byte(manifest), 0644); err != nil {
+  - name: smtp
+    port: 1025
+    targetPort: 1025
+`)
+
+// mailpitManifest renders the Mailpit manifest with the configured
+// replicas, resources and persistence.
+func mailpitManifest(cfg *config.Config) (string, error) {
+	return renderTemplate(mailpitTemplate, newWorkloadData(cfg.Mailpit.Workload, cfg.Mailpit.Persistence, "1Gi"))
+}
+
+// renderMailpit returns the Mailpit manifest.
+func renderMailpit(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]byte, error) {
+	manifest, err := mailpitManifest(cfg)
+	return []byte(manifest), err
+}
+
+// InstallMailpit installs Mailpit and sets up ingress.
+func InstallMailpit(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	// Apply Mailpit manifest
+	manifest, err := mailpitManifest(cfg)
+	if err != nil {
 		return err
 	}
-	cmd := exec.Command("kubectl", "apply", "-f", "mailpit.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, manifest); err != nil {
 		return err
 	}
-	_ = os.Remove("mailpit.yaml")
 
 	// Apply ingress
 	ingressManifest := `
@@ -78,14 +131,9 @@ spec:
             port:
               number: 80
 `
-	if err := os.WriteFile("mailpit-ingress.yaml", []byte(ingressManifest), 0644); err != nil {
-		return err
-	}
-	cmd = exec.Command("kubectl", "apply", "-f", "mailpit-ingress.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, ingressManifest); err != nil {
 		return err
 	}
-	_ = os.Remove("mailpit-ingress.yaml")
 
 	log.Info("Installed Mailpit with ingress: %s", cfg.Mailpit.Ingress)
 	return nil
@@ -0,0 +1,53 @@
+package tools
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// prefixWriter prefixes every line written to it, so the output of tools
+// installed in parallel can be told apart. Complete lines are written
+// atomically under a lock shared by all writers of one destination, which
+// also makes a single writer safe for concurrent use.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(mu *sync.Mutex, w io.Writer, prefix string) *prefixWriter {
+	return &prefixWriter{mu: mu, w: w, prefix: []byte(prefix)}
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, data...)
+	i := bytes.LastIndexByte(p.buf, '\n')
+	if i < 0 {
+		return len(data), nil
+	}
+	lines := p.buf[:i+1]
+	var out []byte
+	for len(lines) > 0 {
+		j := bytes.IndexByte(lines, '\n')
+		out = append(append(out, p.prefix...), lines[:j+1]...)
+		lines = lines[j+1:]
+	}
+	p.buf = append(p.buf[:0], p.buf[i+1:]...)
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush writes a trailing incomplete line.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := p.Write([]byte("\n"))
+	return err
+}
@@ -1,38 +1,32 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
 
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
 )
 
-// InstallPgAdmin installs pgAdmin and sets up ingress.
-func InstallPgAdmin(log *logger.Logger, cfg *config.Config) error {
-	// Ensure Runix Helm repository
-	cmd := exec.Command("helm", "repo", "add", "runix", "https://helm.runix.net")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Warn("Failed to add Runix Helm repo, it may already exist: %v", err)
+// pgadminValues returns the Helm values for the pgAdmin chart.
+func pgadminValues(cfg *config.Config) map[string]interface{} {
+	values := map[string]interface{}{
+		"env": map[string]interface{}{
+			"email":    cfg.PgAdmin.Email,
+			"password": cfg.PgAdmin.Password,
+		},
 	}
-	cmd = exec.Command("helm", "repo", "update")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return err
+	if cfg.PgAdmin.Replicas > 0 {
+		values["replicaCount"] = cfg.PgAdmin.Replicas
 	}
-	log.Info("Ensured Runix Helm repository")
+	setValue(values, "resources", resourceValues(cfg.PgAdmin.Resources))
+	setValue(values, "persistentVolume", persistenceValues(cfg.PgAdmin.Persistence))
+	return values
+}
 
-	// Apply pgAdmin manifest
-	cmd = exec.Command("helm", "install", "pgadmin", "runix/pgadmin4",
-		"--set", "env.email="+cfg.PgAdmin.Email,
-		"--set", "env.password="+cfg.PgAdmin.Password,
-		"--namespace", "default", "--create-namespace")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+// InstallPgAdmin installs pgAdmin and sets up ingress.
+func InstallPgAdmin(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	// Install pgAdmin using the Runix Helm chart
+	if err := helmInstall(ctx, log, cfg, opts, "pgadmin", pgadminValues(cfg)); err != nil {
 		return err
 	}
 
@@ -56,14 +50,9 @@ spec:
             port:
               number: 80
 `
-	if err := os.WriteFile("pgadmin-ingress.yaml", []byte(ingressManifest), 0644); err != nil {
-		return err
-	}
-	cmd = exec.Command("kubectl", "apply", "-f", "pgadmin-ingress.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, ingressManifest); err != nil {
 		return err
 	}
-	_ = os.Remove("pgadmin-ingress.yaml")
 
 	log.Info("Installed pgAdmin with ingress: %s", cfg.PgAdmin.Ingress)
 	return nil
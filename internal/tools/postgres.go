@@ -1,40 +1,44 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
 
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
 )
 
-// InstallPostgres installs PostgreSQL and sets up ingress.
-func InstallPostgres(log *logger.Logger, cfg *config.Config) error {
-	// Ensure Bitnami Helm repository
-	cmd := exec.Command("helm", "repo", "add", "bitnami", "https://charts.bitnami.com/bitnami")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Warn("Failed to add Bitnami Helm repo, it may already exist: %v", err)
+// postgresValues returns the Helm values for the PostgreSQL chart. More than
+// one replica switches to a primary with read replicas.
+func postgresValues(cfg *config.Config) map[string]interface{} {
+	values := map[string]interface{}{
+		"global": map[string]interface{}{
+			"postgresql": map[string]interface{}{
+				"auth": map[string]interface{}{
+					"username": cfg.Postgres.Username,
+					"password": cfg.Postgres.Password,
+					"database": cfg.Postgres.Database,
+				},
+			},
+		},
+		"image": map[string]interface{}{"tag": cfg.Postgres.Version},
 	}
-	cmd = exec.Command("helm", "repo", "update")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return err
+	resources := resourceValues(cfg.Postgres.Resources)
+	persistence := persistenceValues(cfg.Postgres.Persistence)
+	setValue(values, "primary.resources", resources)
+	setValue(values, "primary.persistence", persistence)
+	if cfg.Postgres.Replicas > 1 {
+		values["architecture"] = "replication"
+		setValue(values, "readReplicas.replicaCount", cfg.Postgres.Replicas-1)
+		setValue(values, "readReplicas.resources", resources)
+		setValue(values, "readReplicas.persistence", persistence)
 	}
-	log.Info("Ensured Bitnami Helm repository")
+	return values
+}
 
-	// Apply PostgreSQL manifest (using Bitnami Helm chart)
-	cmd = exec.Command("helm", "install", "postgres", "bitnami/postgresql",
-		"--set", "global.postgresql.auth.username="+cfg.Postgres.Username,
-		"--set", "global.postgresql.auth.password="+cfg.Postgres.Password,
-		"--set", "global.postgresql.auth.database="+cfg.Postgres.Database,
-		"--set", "image.tag="+cfg.Postgres.Version,
-		"--namespace", "default", "--create-namespace")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+// InstallPostgres installs PostgreSQL and sets up ingress.
+func InstallPostgres(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	// Install PostgreSQL using the Bitnami Helm chart
+	if err := helmInstall(ctx, log, cfg, opts, "postgres", postgresValues(cfg)); err != nil {
 		return err
 	}
 
@@ -58,14 +62,9 @@ spec:
             port:
               number: 5432
 `
-	if err := os.WriteFile("postgres-ingress.yaml", []byte(ingressManifest), 0644); err != nil {
-		return err
-	}
-	cmd = exec.Command("kubectl", "apply", "-f", "postgres-ingress.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, ingressManifest); err != nil {
 		return err
 	}
-	_ = os.Remove("postgres-ingress.yaml")
 
 	log.Info("Installed PostgreSQL with ingress: %s", cfg.Postgres.Ingress)
 	return nil
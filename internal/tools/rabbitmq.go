@@ -1,23 +1,32 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
 
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
 )
 
+// rabbitmqValues returns the Helm values for the RabbitMQ chart.
+func rabbitmqValues(cfg *config.Config) map[string]interface{} {
+	values := map[string]interface{}{
+		"auth": map[string]interface{}{
+			"username": cfg.RabbitMQ.Username,
+			"password": cfg.RabbitMQ.Password,
+		},
+	}
+	if cfg.RabbitMQ.Replicas > 0 {
+		values["replicaCount"] = cfg.RabbitMQ.Replicas
+	}
+	setValue(values, "resources", resourceValues(cfg.RabbitMQ.Resources))
+	setValue(values, "persistence", persistenceValues(cfg.RabbitMQ.Persistence))
+	return values
+}
+
 // InstallRabbitMQ installs RabbitMQ and sets up ingress.
-func InstallRabbitMQ(log *logger.Logger, cfg *config.Config) error {
-	// Apply RabbitMQ manifest
-	cmd := exec.Command("helm", "install", "rabbitmq", "bitnami/rabbitmq",
-		"--set", "auth.username="+cfg.RabbitMQ.Username,
-		"--set", "auth.password="+cfg.RabbitMQ.Password,
-		"--namespace", "default", "--create-namespace")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+func InstallRabbitMQ(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	// Install RabbitMQ using the Bitnami Helm chart
+	if err := helmInstall(ctx, log, cfg, opts, "rabbitmq", rabbitmqValues(cfg)); err != nil {
 		return err
 	}
 
@@ -41,14 +50,9 @@ spec:
             port:
               number: 15672
 `
-	if err := os.WriteFile("rabbitmq-ingress.yaml", []byte(ingressManifest), 0644); err != nil {
-		return err
-	}
-	cmd = exec.Command("kubectl", "apply", "-f", "rabbitmq-ingress.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, ingressManifest); err != nil {
 		return err
 	}
-	_ = os.Remove("rabbitmq-ingress.yaml")
 
 	log.Info("Installed RabbitMQ with ingress: %s", cfg.RabbitMQ.Ingress)
 	return nil
@@ -1,37 +1,41 @@
 package tools
 
 import (
-	"os"
-	"os/exec"
+	"context"
 
 	"kindctl/internal/config"
 	"kindctl/internal/logger"
 )
 
-// InstallRedis installs Redis and sets up ingress.
-func InstallRedis(log *logger.Logger, cfg *config.Config) error {
-	// Ensure Bitnami Helm repository
-	cmd := exec.Command("helm", "repo", "add", "bitnami", "https://charts.bitnami.com/bitnami")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		log.Warn("Failed to add Bitnami Helm repo, it may already exist: %v", err)
+// redisValues returns the Helm values for the Redis chart. Authentication,
+// which the chart enables with a random password by default, is only on
+// when a password is configured. More than one replica switches to a
+// primary with read replicas.
+func redisValues(cfg *config.Config) map[string]interface{} {
+	values := map[string]interface{}{
+		"architecture": "standalone",
+		"auth":         map[string]interface{}{"enabled": cfg.Redis.Password != ""},
 	}
-	cmd = exec.Command("helm", "repo", "update")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return err
+	if cfg.Redis.Password != "" {
+		setValue(values, "auth.password", cfg.Redis.Password)
 	}
-	log.Info("Ensured Bitnami Helm repository")
+	resources := resourceValues(cfg.Redis.Resources)
+	persistence := persistenceValues(cfg.Redis.Persistence)
+	setValue(values, "master.resources", resources)
+	setValue(values, "master.persistence", persistence)
+	if cfg.Redis.Replicas > 1 {
+		values["architecture"] = "replication"
+		setValue(values, "replica.replicaCount", cfg.Redis.Replicas-1)
+		setValue(values, "replica.resources", resources)
+		setValue(values, "replica.persistence", persistence)
+	}
+	return values
+}
 
-	// Apply Redis manifest
-	cmd = exec.Command("helm", "install", "redis", "bitnami/redis",
-		"--set", "architecture=standalone",
-		"--namespace", "default", "--create-namespace")
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
+// InstallRedis installs Redis and sets up ingress.
+func InstallRedis(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	// Install Redis using the Bitnami Helm chart
+	if err := helmInstall(ctx, log, cfg, opts, "redis", redisValues(cfg)); err != nil {
 		return err
 	}
 
@@ -55,14 +59,9 @@ spec:
             port:
               number: 6379
 `
-	if err := os.WriteFile("redis-ingress.yaml", []byte(ingressManifest), 0644); err != nil {
-		return err
-	}
-	cmd = exec.Command("kubectl", "apply", "-f", "redis-ingress.yaml")
-	if err := cmd.Run(); err != nil {
+	if err := applyManifest(ctx, cfg, opts, ingressManifest); err != nil {
 		return err
 	}
-	_ = os.Remove("redis-ingress.yaml")
 
 	log.Info("Installed Redis with ingress: %s", cfg.Redis.Ingress)
 	return nil
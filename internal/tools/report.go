@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Status is the outcome of updating one tool.
+type Status string
+
+const (
+	StatusInstalled Status = "installed"
+	StatusUpgraded  Status = "upgraded"
+	StatusUnchanged Status = "unchanged"
+	StatusFailed    Status = "failed"
+	// StatusSkipped marks tools that were not attempted because a tool
+	// they depend on, or any tool without --continue-on-error, failed.
+	StatusSkipped Status = "skipped"
+)
+
+// ToolResult is the outcome of updating one tool.
+type ToolResult struct {
+	Tool     string
+	Status   Status
+	Reason   string
+	Duration time.Duration
+}
+
+// Summary lists the outcome of every enabled tool in installation order.
+type Summary []ToolResult
+
+// Failures returns the number of tools that failed or were skipped.
+func (s Summary) Failures() int {
+	failures := 0
+	for _, r := range s {
+		if r.Status == StatusFailed || r.Status == StatusSkipped {
+			failures++
+		}
+	}
+	return failures
+}
+
+// Write renders the summary as a table.
+func (s Summary) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TOOL\tSTATUS\tDURATION\tREASON")
+	for _, r := range s {
+		duration := "-"
+		if r.Duration > 0 {
+			duration = r.Duration.Round(100 * time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Tool, r.Status, duration, r.Reason)
+	}
+	return tw.Flush()
+}
+
+// changes records what the helm and kubectl calls of one tool changed in
+// the cluster.
+type changes struct {
+	mu         sync.Mutex
+	created    int
+	configured int
+	unchanged  int
+	// revision is the highest Helm release revision installed or upgraded.
+	revision int
+}
+
+// status derives the outcome of a successful install.
+func (c *changes) status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.revision == 1:
+		return StatusInstalled
+	case c.revision > 1:
+		return StatusUpgraded
+	case c.configured > 0 || (c.created > 0 && c.unchanged > 0):
+		return StatusUpgraded
+	case c.created > 0:
+		return StatusInstalled
+	default:
+		return StatusUnchanged
+	}
+}
+
+// recordApply counts the objects in the lines kube.Apply prints, such as
+// "deployment.apps/mailpit created", by their last word: created,
+// configured or unchanged.
+func (c *changes) recordApply(output []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		switch {
+		case strings.HasSuffix(scanner.Text(), " created"):
+			c.created++
+		case strings.HasSuffix(scanner.Text(), " configured"):
+			c.configured++
+		case strings.HasSuffix(scanner.Text(), " unchanged"):
+			c.unchanged++
+		}
+	}
+}
+
+// recordRelease notes the revision of a deployed Helm release, and whether
+// the deployment installed or upgraded it rather than leaving it as it was.
+func (c *changes) recordRelease(revision int, changed bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !changed {
+		c.unchanged++
+		return
+	}
+	if revision > c.revision {
+		c.revision = revision
+	}
+}
@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"kindctl/internal/config"
+	"kindctl/internal/logger"
+)
+
+// ReleaseStatus is the state of the Helm release of a chart-based tool.
+type ReleaseStatus struct {
+	Tool      string
+	Namespace string
+	// Status is the Helm status, such as deployed or failed, or
+	// "not installed".
+	Status   string
+	Revision int
+	// Chart is the installed chart and version, e.g. redis-18.1.0.
+	Chart   string
+	Updated time.Time
+}
+
+// releaseNamespace returns the namespace of the Helm release of a tool, and
+// false if the tool is not installed from a chart.
+func releaseNamespace(cfg *config.Config, name string) (string, bool) {
+	if _, ok := toolCharts[name]; ok {
+		return "default", true
+	}
+	for _, ct := range cfg.Custom {
+		if ct.Name == name && ct.Manifests == "" {
+			return customNamespace(ct), true
+		}
+	}
+	return "", false
+}
+
+// ReleaseStatuses reads the releases of the named chart-based tools back
+// from the cluster, or of all enabled chart-based tools when names is empty.
+func ReleaseStatuses(ctx context.Context, log *logger.Logger, cfg *config.Config, names []string) ([]ReleaseStatus, error) {
+	if len(names) == 0 {
+		enabled, err := EnabledTools(cfg)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range enabled {
+			if _, ok := releaseNamespace(cfg, name); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	client, err := newHelm(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []ReleaseStatus
+	for _, name := range names {
+		namespace, ok := releaseNamespace(cfg, name)
+		if !ok {
+			return nil, fmt.Errorf("%q is not a chart-based tool", name)
+		}
+		rel, err := client.Status(ctx, name, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("reading release of %s: %w", name, err)
+		}
+		status := ReleaseStatus{Tool: name, Namespace: namespace, Status: "not installed"}
+		if rel != nil {
+			status.Status = rel.Info.Status.String()
+			status.Revision = rel.Version
+			status.Chart = rel.Chart.Metadata.Name + "-" + rel.Chart.Metadata.Version
+			status.Updated = rel.Info.LastDeployed.Time
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ReleaseValues returns the values the release of a chart-based tool was
+// installed with.
+func ReleaseValues(ctx context.Context, log *logger.Logger, cfg *config.Config, name string) (map[string]interface{}, error) {
+	namespace, ok := releaseNamespace(cfg, name)
+	if !ok {
+		return nil, fmt.Errorf("%q is not a chart-based tool", name)
+	}
+	client, err := newHelm(cfg, log)
+	if err != nil {
+		return nil, err
+	}
+	return client.Values(ctx, name, namespace)
+}
@@ -1,68 +1,343 @@
 package tools
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
 	"kindctl/internal/config"
+	"kindctl/internal/images"
 	"kindctl/internal/ingress"
 	"kindctl/internal/logger"
 )
 
-// UpdateCluster installs or updates tools in the Kind cluster based on the config.
-func UpdateCluster(log *logger.Logger, cfg *config.Config) error {
-	if cfg.Dashboard.Enabled {
-		if err := InstallDashboard(log, cfg); err != nil {
-			return err
+// UpdateOptions controls how UpdateCluster installs the tools.
+type UpdateOptions struct {
+	// Offline fails instead of reaching out to the network.
+	Offline bool
+	// Parallel bounds the number of tools installed at the same time. Values
+	// below one install the tools one after another.
+	Parallel int
+	// Stdout and Stderr receive the progress of the installs, defaulting
+	// to the process's.
+	Stdout io.Writer
+	Stderr io.Writer
+	// StepTimeout bounds the installation of each tool; zero means no limit.
+	StepTimeout time.Duration
+	// ContinueOnError attempts every tool even after one fails. Tools that
+	// depend on a failed tool are skipped.
+	ContinueOnError bool
+
+	// tool is the name of the tool being installed.
+	tool string
+	// changes collects what the installation of one tool changed.
+	changes *changes
+	// lock holds the chart versions and image digests of the lockfile.
+	lock *lockState
+}
+
+func (opts UpdateOptions) stdout() io.Writer {
+	if opts.Stdout != nil {
+		return opts.Stdout
+	}
+	return os.Stdout
+}
+
+func (opts UpdateOptions) stderr() io.Writer {
+	if opts.Stderr != nil {
+		return opts.Stderr
+	}
+	return os.Stderr
+}
+
+// tool is a component kindctl installs into the cluster.
+type tool struct {
+	name string
+	// deps are installed before the tool when they are enabled.
+	deps    []string
+	enabled func(cfg *config.Config) bool
+	ingress func(cfg *config.Config) string
+	install func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error
+	// remove prunes the tool once it is disabled. Tools without it are left
+	// in place.
+	remove func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error
+	// render returns the manifests of the tool's workload, which the
+	// container images it runs are resolved from.
+	render func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]byte, error)
+}
+
+// images resolves the container images the tool runs.
+func (t tool) images(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]string, error) {
+	manifest, err := t.render(ctx, log, cfg, opts)
+	if err != nil {
+		return nil, err
+	}
+	return images.Extract(manifest)
+}
+
+// registry lists the built-in tools in installation order.
+var registry = []tool{
+	{
+		name:    "dashboard",
+		enabled: func(cfg *config.Config) bool { return cfg.Dashboard.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.Dashboard.Ingress },
+		install: InstallDashboard,
+		render:  renderDashboard,
+	},
+	{
+		name:    "postgres",
+		enabled: func(cfg *config.Config) bool { return cfg.Postgres.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.Postgres.Ingress },
+		install: InstallPostgres,
+		render:  renderChart("postgres", postgresValues),
+	},
+	{
+		name:    "redis",
+		enabled: func(cfg *config.Config) bool { return cfg.Redis.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.Redis.Ingress },
+		install: InstallRedis,
+		render:  renderChart("redis", redisValues),
+	},
+	{
+		name:    "pgadmin",
+		deps:    []string{"postgres"},
+		enabled: func(cfg *config.Config) bool { return cfg.PgAdmin.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.PgAdmin.Ingress },
+		install: InstallPgAdmin,
+		render:  renderChart("pgadmin", pgadminValues),
+	},
+	{
+		name:    "adminer",
+		deps:    []string{"postgres"},
+		enabled: func(cfg *config.Config) bool { return cfg.Adminer.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.Adminer.Ingress },
+		install: InstallAdminer,
+		render:  renderAdminer,
+	},
+	{
+		name:    "rabbitmq",
+		enabled: func(cfg *config.Config) bool { return cfg.RabbitMQ.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.RabbitMQ.Ingress },
+		install: InstallRabbitMQ,
+		render:  renderChart("rabbitmq", rabbitmqValues),
+	},
+	{
+		name:    "mailpit",
+		enabled: func(cfg *config.Config) bool { return cfg.Mailpit.Enabled },
+		ingress: func(cfg *config.Config) string { return cfg.Mailpit.Ingress },
+		install: InstallMailpit,
+		render:  renderMailpit,
+	},
+}
+
+// EnabledTools returns the names of the tools enabled in the config, in
+// installation order.
+func EnabledTools(cfg *config.Config) ([]string, error) {
+	all, err := allTools(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, t := range all {
+		if t.enabled(cfg) {
+			names = append(names, t.name)
 		}
-		if err := ingress.AddHostEntry(log, cfg.Dashboard.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.Dashboard.Ingress, err)
+	}
+	return names, nil
+}
+
+// ToolImages lists the images used by one tool.
+type ToolImages struct {
+	Tool   string
+	Images []string
+	// Digests maps the images locked in kindctl.lock to their digests.
+	Digests map[string]string
+}
+
+// Images resolves the container images used by the named tools, or by the
+// enabled tools when names is empty.
+func Images(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, names []string) ([]ToolImages, error) {
+	if len(names) == 0 {
+		var err error
+		if names, err = EnabledTools(cfg); err != nil {
+			return nil, err
 		}
 	}
-	if cfg.Postgres.Enabled {
-		if err := InstallPostgres(log, cfg); err != nil {
-			return err
+	if opts.lock == nil {
+		state, err := openLock(log, cfg, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.lock = state
+	}
+	var result []ToolImages
+	for _, name := range names {
+		t, err := lookupTool(cfg, name)
+		if err != nil {
+			return nil, err
 		}
-		if err := ingress.AddHostEntry(log, cfg.Postgres.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.Postgres.Ingress, err)
+		opts.tool = name
+		list, err := t.images(ctx, log, cfg, opts)
+		if err != nil {
+			return nil, fmt.Errorf("resolving images of %s: %w", name, err)
 		}
+		result = append(result, ToolImages{Tool: name, Images: list, Digests: opts.lock.locked(name)})
 	}
-	if cfg.Redis.Enabled {
-		if err := InstallRedis(log, cfg); err != nil {
-			return err
+	return result, nil
+}
+
+// ToolManifest is the rendered manifest of one tool.
+type ToolManifest struct {
+	Tool     string
+	Manifest []byte
+}
+
+// Render renders the main manifest or chart of the named tools, or of the
+// enabled tools when names is empty, with the settings and chart versions
+// they would be installed with. Images keep their tags rather than the
+// digests they are pinned to on install, and the Ingress and ServiceAccount
+// objects kindctl applies next to them are left out.
+func Render(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions, names []string) ([]ToolManifest, error) {
+	if len(names) == 0 {
+		var err error
+		if names, err = EnabledTools(cfg); err != nil {
+			return nil, err
 		}
-		if err := ingress.AddHostEntry(log, cfg.Redis.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.Redis.Ingress, err)
+	}
+	if opts.lock == nil {
+		state, err := openLock(log, cfg, opts)
+		if err != nil {
+			return nil, err
 		}
+		opts.lock = state
 	}
-	if cfg.PgAdmin.Enabled {
-		if err := InstallPgAdmin(log, cfg); err != nil {
-			return err
+	var result []ToolManifest
+	for _, name := range names {
+		t, err := lookupTool(cfg, name)
+		if err != nil {
+			return nil, err
 		}
-		if err := ingress.AddHostEntry(log, cfg.PgAdmin.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.PgAdmin.Ingress, err)
+		opts.tool = name
+		manifest, err := t.render(ctx, log, cfg, opts)
+		if err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", name, err)
 		}
+		result = append(result, ToolManifest{Tool: name, Manifest: manifest})
+	}
+	return result, nil
+}
+
+// PreloadImages pulls the images of the enabled tools into the local Docker
+// cache and loads them into the cluster nodes.
+func PreloadImages(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	resolved, err := Images(ctx, log, cfg, opts, nil)
+	if err != nil {
+		return err
+	}
+	var all []string
+	for _, ti := range resolved {
+		all = append(all, ti.Images...)
 	}
-	if cfg.Adminer.Enabled {
-		if err := InstallAdminer(log, cfg); err != nil {
+	if err := images.Pull(ctx, log, all, opts.Offline); err != nil {
+		return err
+	}
+	return images.Load(ctx, log, cfg.Cluster.Name, all)
+}
+
+// lookupTool returns the built-in or custom tool called name.
+func lookupTool(cfg *config.Config, name string) (tool, error) {
+	all, err := allTools(cfg)
+	if err != nil {
+		return tool{}, err
+	}
+	for _, t := range all {
+		if t.name == name {
+			return t, nil
+		}
+	}
+	return tool{}, fmt.Errorf("unknown tool %q", name)
+}
+
+// renderChart renders the chart of a chart-based tool.
+func renderChart(name string, values func(cfg *config.Config) map[string]interface{}) func(context.Context, *logger.Logger, *config.Config, UpdateOptions) ([]byte, error) {
+	return func(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) ([]byte, error) {
+		return helmTemplate(ctx, log, cfg, opts, name, values(cfg))
+	}
+}
+
+// UpdateCluster installs or updates tools in the Kind cluster based on the
+// config. Tools are installed after the tools they depend on, independent
+// tools in parallel up to opts.Parallel at a time. A summary of the outcome
+// of every tool is written to opts.Stdout.
+func UpdateCluster(ctx context.Context, log *logger.Logger, cfg *config.Config, opts UpdateOptions) error {
+	state, err := openLock(log, cfg, opts)
+	if err != nil {
+		return err
+	}
+	opts.lock = state
+	if cfg.Cluster.PreloadImages {
+		if err := PreloadImages(ctx, log, cfg, opts); err != nil {
 			return err
 		}
-		if err := ingress.AddHostEntry(log, cfg.Adminer.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.Adminer.Ingress, err)
+	}
+	all, err := allTools(cfg)
+	if err != nil {
+		return err
+	}
+	var enabled []tool
+	var summary Summary
+	for _, t := range all {
+		if t.enabled(cfg) {
+			enabled = append(enabled, t)
+			continue
+		}
+		if t.remove == nil {
+			continue
+		}
+		if err := t.remove(ctx, log, cfg, opts); err != nil {
+			err = fmt.Errorf("removing %s: %w", t.name, err)
+			if !opts.ContinueOnError {
+				return err
+			}
+			summary = append(summary, ToolResult{Tool: t.name, Status: StatusFailed, Reason: err.Error()})
 		}
 	}
-	if cfg.RabbitMQ.Enabled {
-		if err := InstallRabbitMQ(log, cfg); err != nil {
-			return err
+	g, err := newGraph(all, enabled)
+	if err != nil {
+		return err
+	}
+	results, firstErr := g.run(ctx, log, opts, func(ctx context.Context, log *logger.Logger, t tool, opts UpdateOptions) error {
+		return t.install(ctx, log, cfg, opts)
+	}, func(t tool) error {
+		host := t.ingress(cfg)
+		if host == "" {
+			return nil
 		}
-		if err := ingress.AddHostEntry(log, cfg.RabbitMQ.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.RabbitMQ.Ingress, err)
+		if err := ingress.AddHostEntry(ctx, log, host); err != nil {
+			if opts.ContinueOnError {
+				return fmt.Errorf("adding /etc/hosts entry for %s: %w", host, err)
+			}
+			log.Warnf("Failed to add /etc/hosts entry for %s: %v", host, err)
 		}
+		return nil
+	})
+	summary = append(summary, results...)
+	if err := opts.lock.save(); err != nil {
+		return err
 	}
-	if cfg.Mailpit.Enabled {
-		if err := InstallMailpit(log, cfg); err != nil {
+	if len(summary) > 0 {
+		fmt.Fprintln(opts.stdout())
+		if err := summary.Write(opts.stdout()); err != nil {
 			return err
 		}
-		if err := ingress.AddHostEntry(log, cfg.Mailpit.Ingress); err != nil {
-			log.Warn("Failed to add /etc/hosts entry for %s: %v", cfg.Mailpit.Ingress, err)
-		}
+	}
+	if !opts.ContinueOnError {
+		return firstErr
+	}
+	if failed := summary.Failures(); failed > 0 {
+		return fmt.Errorf("%d of %d tools failed", failed, len(summary))
 	}
 	return nil
 }
@@ -1,10 +1,30 @@
 package tools
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 	"kindctl/internal/config"
+	"kindctl/internal/images"
+	"kindctl/internal/kube"
 	"kindctl/internal/logger"
 )
 
@@ -14,8 +34,531 @@ func TestUpdateCluster(t *testing.T) {
 	cfg.Postgres.Enabled = true
 	cfg.Postgres.Ingress = "postgres.local"
 
+	// UpdateCluster writes kindctl.lock next to the configuration, which
+	// is the working directory for a configuration without a file.
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
 	// Note: Actual tool installation requires kubectl/helm, tested in integration tests.
 	// This test verifies the function structure.
-	err := UpdateCluster(log, cfg)
+	err = UpdateCluster(context.Background(), log, cfg, UpdateOptions{})
 	assert.Error(t, err) // Expect error due to missing kubectl/helm in test env
 }
+
+func TestCachedChart(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	c := toolCharts["postgres"]
+	dir, err := c.cacheDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+
+	path, err := c.cached()
+	assert.NoError(t, err)
+	assert.Empty(t, path)
+
+	for _, name := range []string{"postgresql-9.4.0.tgz", "postgresql-15.5.1.tgz", "postgresql-ha-20.0.0.tgz"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+	path, err = c.cached()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "postgresql-15.5.1.tgz"), path)
+
+	cfg := config.DefaultConfig()
+	cfg.Postgres.ChartVersion = "9.4.0"
+	c = toolChart(cfg, "postgres")
+	path, err = c.cached()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "postgresql-9.4.0.tgz"), path)
+}
+
+func TestCustomChartCache(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	cfg := config.DefaultConfig()
+	cfg.Custom = []config.CustomTool{
+		{Name: "grafana", Chart: config.CustomChart{Repo: "https://grafana.github.io/helm-charts", Name: "grafana"}},
+		{Name: "loki", Chart: config.CustomChart{Name: "oci://ghcr.io/grafana/helm-charts/loki", Version: "6.6.2"}},
+		{Name: "local", Chart: config.CustomChart{Name: "charts/local"}},
+	}
+	charts, err := ChartTools(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"grafana", "loki", "pgadmin", "postgres", "rabbitmq", "redis"}, charts)
+
+	c, ok := remoteChart(cfg, "loki")
+	assert.True(t, ok)
+	assert.Equal(t, "oci://ghcr.io/grafana/helm-charts/loki", c.Ref())
+	dir, err := c.cacheDir()
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "loki-6.6.2.tgz"), nil, 0644))
+	path, err := c.cached()
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "loki-6.6.2.tgz"), path)
+
+	_, ok = remoteChart(cfg, "local")
+	assert.False(t, ok)
+}
+
+func TestToolValues(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, config.FileName)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "postgres-values.yaml"), []byte(`primary:
+  persistence:
+    enabled: false
+metrics:
+  enabled: false
+`), 0644))
+	assert.NoError(t, os.WriteFile(path, []byte(`postgres:
+  enabled: true
+  version: "15"
+  valuesFiles: [postgres-values.yaml]
+  values:
+    metrics:
+      enabled: true
+    image:
+      registry: docker.io
+`), 0644))
+	cfg, err := config.LoadConfig(path)
+	assert.NoError(t, err)
+
+	values, err := toolValues(cfg, "postgres", postgresValues(cfg))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"tag": "15", "registry": "docker.io"}, values["image"])
+	assert.Equal(t, map[string]interface{}{"enabled": true}, values["metrics"])
+	assert.Equal(t, map[string]interface{}{"persistence": map[string]interface{}{"enabled": false}}, values["primary"])
+	assert.Contains(t, values, "global")
+
+	cfg.Postgres.ValuesFiles = []string{"missing.yaml"}
+	_, err = toolValues(cfg, "postgres", postgresValues(cfg))
+	assert.ErrorContains(t, err, "values file of postgres")
+}
+
+func TestWorkloadValues(t *testing.T) {
+	cfg := config.DefaultConfig()
+	enabled := false
+	cfg.Postgres.Replicas = 2
+	cfg.Postgres.Resources.Limits.Memory = "512Mi"
+	cfg.Postgres.Persistence = config.Persistence{Enabled: &enabled, StorageClass: "standard"}
+	values := postgresValues(cfg)
+	assert.Equal(t, "replication", values["architecture"])
+	assert.Equal(t, map[string]interface{}{
+		"resources":   map[string]interface{}{"limits": map[string]interface{}{"memory": "512Mi"}},
+		"persistence": map[string]interface{}{"enabled": false, "storageClass": "standard"},
+	}, values["primary"])
+	assert.Equal(t, 1, values["readReplicas"].(map[string]interface{})["replicaCount"])
+
+	// Unset settings keep the chart defaults, except for Redis
+	// authentication, which is off without a password.
+	values = redisValues(config.DefaultConfig())
+	assert.Equal(t, map[string]interface{}{"architecture": "standalone", "auth": map[string]interface{}{"enabled": false}}, values)
+	cfg.Redis.Password = "s3cret"
+	assert.Equal(t, map[string]interface{}{"enabled": true, "password": "s3cret"}, redisValues(cfg)["auth"])
+
+	cfg.PgAdmin.Persistence.Size = "2Gi"
+	cfg.PgAdmin.Replicas = 1
+	values = pgadminValues(cfg)
+	assert.Equal(t, map[string]interface{}{"size": "2Gi"}, values["persistentVolume"])
+	assert.Equal(t, 1, values["replicaCount"])
+}
+
+func TestManifestTemplates(t *testing.T) {
+	cfg := config.DefaultConfig()
+	manifest, err := adminerManifest(cfg)
+	assert.NoError(t, err)
+	assert.Contains(t, manifest, "replicas: 1\n")
+	assert.NotContains(t, manifest, "resources:")
+
+	enabled := true
+	cfg.Mailpit.Replicas = 2
+	cfg.Mailpit.Resources.Requests = config.ResourceList{CPU: "50m", Memory: "64Mi"}
+	cfg.Mailpit.Persistence = config.Persistence{Enabled: &enabled, StorageClass: "standard"}
+	manifest, err = mailpitManifest(cfg)
+	assert.NoError(t, err)
+	objects, err := kube.Decode([]byte(manifest))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+	assert.Equal(t, "PersistentVolumeClaim", objects[0].GetKind())
+	storage, _, _ := unstructured.NestedString(objects[0].Object, "spec", "resources", "requests", "storage")
+	assert.Equal(t, "1Gi", storage)
+	replicas, _, _ := unstructured.NestedFieldNoCopy(objects[1].Object, "spec", "replicas")
+	assert.EqualValues(t, 2, replicas)
+	containers, _, _ := unstructured.NestedSlice(objects[1].Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, map[string]interface{}{"requests": map[string]interface{}{"cpu": "50m", "memory": "64Mi"}},
+		containers[0].(map[string]interface{})["resources"])
+	// A pinned tag lets preloaded images be used without pulling them.
+	images, err := images.Extract([]byte(manifest))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"axllent/mailpit:v1.20.0"}, images)
+	assert.Equal(t, "IfNotPresent", containers[0].(map[string]interface{})["imagePullPolicy"])
+}
+
+func TestSizeDeployment(t *testing.T) {
+	manifest := []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: kubernetes-dashboard
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: kubernetes-dashboard
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: kubernetes-dashboard
+        image: kubernetesui/dashboard:v2.7.0
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: dashboard-metrics-scraper
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: dashboard-metrics-scraper
+        image: kubernetesui/metrics-scraper:v1.0.8
+`)
+	sized, err := sizeDeployment(manifest, "kubernetes-dashboard", config.Workload{})
+	assert.NoError(t, err)
+	assert.Equal(t, manifest, sized)
+
+	sized, err = sizeDeployment(manifest, "kubernetes-dashboard", config.Workload{Replicas: 2, Resources: config.Resources{Limits: config.ResourceList{CPU: "500m"}}})
+	assert.NoError(t, err)
+	objects, err := kube.Decode(sized)
+	assert.NoError(t, err)
+	assert.Len(t, objects, 3)
+	replicas, _, _ := unstructured.NestedFieldNoCopy(objects[1].Object, "spec", "replicas")
+	assert.EqualValues(t, 2, replicas)
+	containers, _, _ := unstructured.NestedSlice(objects[1].Object, "spec", "template", "spec", "containers")
+	assert.Equal(t, map[string]interface{}{"limits": map[string]interface{}{"cpu": "500m"}},
+		containers[0].(map[string]interface{})["resources"])
+
+	replicas, _, _ = unstructured.NestedFieldNoCopy(objects[2].Object, "spec", "replicas")
+	assert.EqualValues(t, 1, replicas)
+	containers, _, _ = unstructured.NestedSlice(objects[2].Object, "spec", "template", "spec", "containers")
+	assert.NotContains(t, containers[0].(map[string]interface{}), "resources")
+}
+
+func TestDashboardManifests(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Dashboard.Ingress = "k8s.local"
+	objects, err := kube.Decode([]byte(dashboardIngress(cfg)))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 1)
+	ingress := objects[0]
+	assert.Equal(t, "Ingress", ingress.GetKind())
+	assert.Equal(t, dashboardNamespace, ingress.GetNamespace())
+	assert.Equal(t, "HTTPS", ingress.GetAnnotations()["nginx.ingress.kubernetes.io/backend-protocol"])
+	rules, _, _ := unstructured.NestedSlice(ingress.Object, "spec", "rules")
+	assert.Equal(t, "k8s.local", rules[0].(map[string]interface{})["host"])
+	paths, _, _ := unstructured.NestedSlice(rules[0].(map[string]interface{}), "http", "paths")
+	service, _, _ := unstructured.NestedMap(paths[0].(map[string]interface{}), "backend", "service")
+	assert.Equal(t, "kubernetes-dashboard", service["name"])
+
+	objects, err = kube.Decode([]byte(dashboardAdmin))
+	assert.NoError(t, err)
+	assert.Len(t, objects, 2)
+	assert.Equal(t, "ServiceAccount", objects[0].GetKind())
+	assert.Equal(t, dashboardAdminUser, objects[0].GetName())
+	assert.Equal(t, dashboardNamespace, objects[0].GetNamespace())
+	assert.Equal(t, "ClusterRoleBinding", objects[1].GetKind())
+	role, _, _ := unstructured.NestedString(objects[1].Object, "roleRef", "name")
+	assert.Equal(t, "cluster-admin", role)
+	subjects, _, _ := unstructured.NestedSlice(objects[1].Object, "subjects")
+	assert.Equal(t, map[string]interface{}{"kind": "ServiceAccount", "name": dashboardAdminUser, "namespace": dashboardNamespace}, subjects[0])
+}
+
+func TestDashboardToken(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	var requested *authenticationv1.TokenRequest
+	clientset.PrependReactor("create", "serviceaccounts", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		create := action.(k8stesting.CreateAction)
+		if create.GetSubresource() != "token" {
+			return false, nil, nil
+		}
+		if _, err := clientset.Tracker().Get(corev1.SchemeGroupVersion.WithResource("serviceaccounts"), create.GetNamespace(), create.(k8stesting.CreateActionImpl).Name); err != nil {
+			return true, nil, err
+		}
+		requested = create.GetObject().(*authenticationv1.TokenRequest)
+		return true, &authenticationv1.TokenRequest{Status: authenticationv1.TokenRequestStatus{Token: "t0ken"}}, nil
+	})
+	ctx := context.Background()
+
+	_, err := dashboardToken(ctx, clientset, time.Hour)
+	assert.ErrorContains(t, err, "set dashboard.adminUser to true")
+
+	_, err = clientset.CoreV1().ServiceAccounts(dashboardNamespace).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: dashboardAdminUser, Namespace: dashboardNamespace}}, metav1.CreateOptions{})
+	assert.NoError(t, err)
+	token, err := dashboardToken(ctx, clientset, time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, "t0ken", token)
+	assert.Equal(t, int64(3600), *requested.Spec.ExpirationSeconds)
+}
+
+func TestLockState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), config.FileName)
+	assert.NoError(t, os.WriteFile(path, []byte("postgres:\n  enabled: true\n"), 0644))
+	cfg, err := config.LoadConfig(path)
+	assert.NoError(t, err)
+	log := logger.NewLogger("error")
+
+	state, err := openLock(log, cfg, UpdateOptions{})
+	assert.NoError(t, err)
+	assert.True(t, state.pinImages)
+	assert.Empty(t, state.chartVersion("postgres", "bitnami/postgresql"))
+	state.recordChart("postgres", "bitnami/postgresql", "15.5.1")
+	state.file.Tool("postgres").Images = map[string]string{"postgres:16": "sha256:abc"}
+	assert.NoError(t, state.save())
+
+	state, err = openLock(log, cfg, UpdateOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "15.5.1", state.chartVersion("postgres", "bitnami/postgresql"))
+	assert.Empty(t, state.chartVersion("postgres", "other/postgresql"))
+	assert.Equal(t, map[string]string{"postgres:16": "sha256:abc"}, state.locked("postgres"))
+
+	// Locked digests are used without looking them up; unknown images stay
+	// unpinned offline.
+	state.offline = true
+	pinned, err := state.pin(context.Background(), "postgres", []byte("containers:\n- image: postgres:16\n- image: busybox:1.36\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "containers:\n- image: postgres:16@sha256:abc\n- image: busybox:1.36\n", string(pinned))
+
+	// A new chart version drops the digests of the previous one.
+	state.recordChart("postgres", "bitnami/postgresql", "16.0.0")
+	assert.Nil(t, state.locked("postgres"))
+
+	var none *lockState
+	assert.Empty(t, none.chartVersion("postgres", "bitnami/postgresql"))
+	manifest, err := none.pin(context.Background(), "postgres", []byte("image: postgres:16\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "image: postgres:16\n", string(manifest))
+}
+
+func TestCustomTools(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "search"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "search", "deployment.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: search
+spec:
+  template:
+    spec:
+      containers:
+      - name: search
+        image: elasticsearch:8.13.0
+`), 0644))
+	path := filepath.Join(dir, config.FileName)
+	assert.NoError(t, os.WriteFile(path, []byte(`custom:
+  - name: search
+    enabled: true
+    manifests: search
+    ingress:
+      host: search.local
+      port: 9200
+  - name: grafana
+    chart:
+      repo: https://grafana.github.io/helm-charts
+      name: grafana
+`), 0644))
+	cfg, err := config.LoadConfig(path)
+	assert.NoError(t, err)
+
+	enabled, err := EnabledTools(cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"search"}, enabled)
+	resolved, err := Images(context.Background(), logger.NewLogger("info"), cfg, UpdateOptions{}, []string{"search"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"elasticsearch:8.13.0"}, resolved[0].Images)
+	assert.Contains(t, customIngress(cfg.Custom[0]), "number: 9200")
+
+	cfg.Custom = append(cfg.Custom, config.CustomTool{Name: "postgres", Manifests: "pg.yaml"})
+	_, err = allTools(cfg)
+	assert.ErrorContains(t, err, "clashes")
+	_, err = EnabledTools(cfg)
+	assert.ErrorContains(t, err, "clashes")
+	_, err = Images(context.Background(), logger.NewLogger("info"), cfg, UpdateOptions{}, []string{"search"})
+	assert.ErrorContains(t, err, "clashes")
+	assert.Error(t, validateCustom(config.CustomTool{Name: "both", Manifests: "x", Chart: config.CustomChart{Name: "y"}}))
+	assert.Error(t, validateCustom(config.CustomTool{Name: "values", Manifests: "x", ValuesFile: "values.yaml"}))
+}
+
+func TestPruneCustom(t *testing.T) {
+	deployments := schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+	ingresses := schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"}
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "Ingress"}, meta.RESTScopeNamespace)
+	object := func(apiVersion, kind, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetName(name)
+		obj.SetNamespace("default")
+		return obj
+	}
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{deployments: "DeploymentList", ingresses: "IngressList"},
+		object("apps/v1", "Deployment", "search"),
+		object("networking.k8s.io/v1", "Ingress", "search-ingress"),
+		object("networking.k8s.io/v1", "Ingress", "grafana-ingress"))
+	client := kube.New(fake.NewSimpleClientset(), dyn, mapper)
+	ctx := context.Background()
+	log := logger.NewLogger("error")
+	uninstalled := 0
+	uninstall := func() (bool, error) {
+		uninstalled++
+		return false, nil
+	}
+
+	search := config.CustomTool{Name: "search", Manifests: "search"}
+	assert.NoError(t, client.SaveInventory(ctx, customNamespace(search), search.Name, []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: search
+`)))
+	var out bytes.Buffer
+	assert.NoError(t, pruneCustom(ctx, log, client, uninstall, UpdateOptions{Stdout: &out}, search))
+	assert.Equal(t, "deployment.apps/search deleted\n", out.String(), "the ingress is kept without a host")
+
+	out.Reset()
+	assert.NoError(t, pruneCustom(ctx, log, client, uninstall, UpdateOptions{Stdout: &out}, search))
+	assert.Empty(t, out.String(), "the inventory is deleted with the tool")
+
+	grafana := config.CustomTool{Name: "grafana", Chart: config.CustomChart{Name: "grafana"}, Ingress: config.CustomIngress{Host: "grafana.local"}}
+	assert.NoError(t, pruneCustom(ctx, log, client, uninstall, UpdateOptions{Stdout: &out}, grafana))
+	assert.Equal(t, 1, uninstalled)
+	assert.Equal(t, "ingress.networking.k8s.io/grafana-ingress deleted\n", out.String())
+}
+
+func TestGraph(t *testing.T) {
+	all := []tool{
+		{name: "postgres"},
+		{name: "pgadmin", deps: []string{"postgres"}},
+		{name: "adminer", deps: []string{"postgres"}},
+		{name: "redis"},
+		{name: "mailpit"},
+	}
+	log := logger.NewLogger("error")
+
+	g, err := newGraph(all, all[1:])
+	assert.NoError(t, err)
+	assert.Empty(t, g.deps["pgadmin"])
+
+	g, err = newGraph(all, all)
+	assert.NoError(t, err)
+	var mu sync.Mutex
+	var order []string
+	active, maxActive := 0, 0
+	_, err = g.run(context.Background(), log, UpdateOptions{Parallel: 2, Stdout: io.Discard, Stderr: io.Discard}, func(ctx context.Context, log *logger.Logger, tl tool, opts UpdateOptions) error {
+		mu.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+		return nil
+	}, func(tl tool) error {
+		order = append(order, tl.name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, maxActive)
+	assert.Len(t, order, 5)
+	index := map[string]int{}
+	for i, name := range order {
+		index[name] = i
+	}
+	assert.Less(t, index["postgres"], index["pgadmin"])
+	assert.Less(t, index["postgres"], index["adminer"])
+
+	fail := func(ctx context.Context, log *logger.Logger, tl tool, opts UpdateOptions) error {
+		if tl.name == "postgres" {
+			return errors.New("boom")
+		}
+		return nil
+	}
+	summary, err := g.run(context.Background(), log, UpdateOptions{}, fail, func(tool) error { return nil })
+	assert.EqualError(t, err, "installing postgres: boom")
+	assert.Equal(t, StatusFailed, summary[0].Status)
+	assert.Equal(t, "boom", summary[0].Reason)
+	assert.Equal(t, 5, summary.Failures())
+
+	summary, err = g.run(context.Background(), log, UpdateOptions{ContinueOnError: true}, fail, func(tl tool) error {
+		if tl.name == "mailpit" {
+			return errors.New("hosts file is read-only")
+		}
+		return nil
+	})
+	assert.Error(t, err)
+	var statuses []Status
+	for _, r := range summary {
+		statuses = append(statuses, r.Status)
+	}
+	assert.Equal(t, []Status{StatusFailed, StatusSkipped, StatusSkipped, StatusUnchanged, StatusFailed}, statuses)
+	assert.Equal(t, "postgres failed", summary[1].Reason)
+
+	_, err = newGraph(all, []tool{{name: "a", deps: []string{"b"}}, {name: "b", deps: []string{"a"}}})
+	assert.ErrorContains(t, err, "unknown tool")
+	cyclic := []tool{{name: "a", deps: []string{"b"}}, {name: "b", deps: []string{"a"}}}
+	_, err = newGraph(cyclic, cyclic)
+	assert.EqualError(t, err, "dependency cycle between tools: a -> b -> a")
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	w := newPrefixWriter(&mu, &buf, "[redis] ")
+	_, _ = w.Write([]byte("one\ntw"))
+	_, _ = w.Write([]byte("o\nthree"))
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "[redis] one\n[redis] two\n[redis] three\n", buf.String())
+}
+
+func TestChanges(t *testing.T) {
+	c := &changes{}
+	c.recordApply([]byte("deployment.apps/adminer created\nservice/adminer created\n"))
+	assert.Equal(t, StatusInstalled, c.status())
+	c.recordApply([]byte("ingress.networking.k8s.io/adminer-ingress unchanged\n"))
+	assert.Equal(t, StatusUpgraded, c.status())
+
+	c = &changes{}
+	c.recordApply([]byte("service/adminer unchanged\n"))
+	assert.Equal(t, StatusUnchanged, c.status())
+	c.recordRelease(3, false)
+	assert.Equal(t, StatusUnchanged, c.status())
+	c.recordRelease(3, true)
+	assert.Equal(t, StatusUpgraded, c.status())
+	c = &changes{}
+	c.recordRelease(1, true)
+	assert.Equal(t, StatusInstalled, c.status())
+
+	var buf bytes.Buffer
+	assert.NoError(t, Summary{{Tool: "redis", Status: StatusFailed, Reason: "timed out"}}.Write(&buf))
+	assert.Equal(t, "TOOL   STATUS  DURATION  REASON\nredis  failed  -         timed out\n", buf.String())
+}
+
+func TestStepTimeout(t *testing.T) {
+	all := []tool{{name: "redis"}}
+	g, err := newGraph(all, all)
+	assert.NoError(t, err)
+	summary, err := g.run(context.Background(), logger.NewLogger("error"), UpdateOptions{StepTimeout: 10 * time.Millisecond},
+		func(ctx context.Context, log *logger.Logger, tl tool, opts UpdateOptions) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, func(tool) error { return nil })
+	assert.ErrorContains(t, err, "timed out after 10ms")
+	assert.Equal(t, StatusFailed, summary[0].Status)
+}
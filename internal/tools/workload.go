@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+	"kindctl/internal/config"
+)
+
+// resourceValues returns the requests and limits of a tool's containers in
+// the form pods and charts take them, or nil if none are set.
+func resourceValues(r config.Resources) map[string]interface{} {
+	values := map[string]interface{}{}
+	for key, list := range map[string]config.ResourceList{"requests": r.Requests, "limits": r.Limits} {
+		quantities := map[string]interface{}{}
+		if list.CPU != "" {
+			quantities["cpu"] = list.CPU
+		}
+		if list.Memory != "" {
+			quantities["memory"] = list.Memory
+		}
+		if len(quantities) > 0 {
+			values[key] = quantities
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// persistenceValues returns the persistence settings under the keys the
+// charts use, or nil if none are set.
+func persistenceValues(p config.Persistence) map[string]interface{} {
+	values := map[string]interface{}{}
+	if p.Enabled != nil {
+		values["enabled"] = *p.Enabled
+	}
+	if p.Size != "" {
+		values["size"] = p.Size
+	}
+	if p.StorageClass != "" {
+		values["storageClass"] = p.StorageClass
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// setValue sets the value at a dotted path of chart values, creating the
+// maps on the way. Nil and empty map values are not set.
+func setValue(values map[string]interface{}, path string, value interface{}) {
+	if m, ok := value.(map[string]interface{}); value == nil || ok && len(m) == 0 {
+		return
+	}
+	keys := strings.Split(path, ".")
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			values[key] = next
+		}
+		values = next
+	}
+	values[keys[len(keys)-1]] = value
+}
+
+// workloadData is what the manifest templates of the tools are executed
+// with.
+type workloadData struct {
+	Replicas    int
+	Resources   map[string]interface{}
+	Persistence struct {
+		Enabled      bool
+		Size         string
+		StorageClass string
+	}
+}
+
+// newWorkloadData applies the defaults of a manifest-based tool: one
+// replica, and no persistence unless enabled, with a claim of defaultSize.
+func newWorkloadData(w config.Workload, p config.Persistence, defaultSize string) workloadData {
+	data := workloadData{Replicas: w.Replicas, Resources: resourceValues(w.Resources)}
+	if data.Replicas < 1 {
+		data.Replicas = 1
+	}
+	data.Persistence.Enabled = p.Enabled != nil && *p.Enabled
+	data.Persistence.Size = p.Size
+	if data.Persistence.Size == "" {
+		data.Persistence.Size = defaultSize
+	}
+	data.Persistence.StorageClass = p.StorageClass
+	return data
+}
+
+// manifestTemplate parses the manifest template of a tool. Templates can
+// render values as YAML indented by n spaces with {{ yaml n .Value }}.
+func manifestTemplate(name, text string) *template.Template {
+	return template.Must(template.New(name).Option("missingkey=error").Funcs(template.FuncMap{
+		"yaml": func(indent int, value interface{}) (string, error) {
+			data, err := yaml.Marshal(value)
+			if err != nil {
+				return "", err
+			}
+			pad := strings.Repeat(" ", indent)
+			return pad + strings.ReplaceAll(strings.TrimSpace(string(data)), "\n", "\n"+pad), nil
+		},
+	}).Parse(text))
+}
+
+// renderTemplate executes a manifest template.
+func renderTemplate(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// sizeDeployment sets the replicas and the container resources of the
+// Deployment called name in a manifest kindctl does not template, such as
+// the dashboard's; its other Deployments are left alone. The manifest is
+// returned unchanged if w sets nothing.
+func sizeDeployment(manifest []byte, name string, w config.Workload) ([]byte, error) {
+	resources := resourceValues(w.Resources)
+	if w.Replicas < 1 && resources == nil {
+		return manifest, nil
+	}
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	dec := yaml.NewDecoder(bytes.NewReader(manifest))
+	for {
+		var doc map[string]interface{}
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc) == 0 {
+			continue
+		}
+		metadata, _ := nested(doc, "metadata")
+		if doc["kind"] == "Deployment" && metadata["name"] == name {
+			if w.Replicas > 0 {
+				setValue(doc, "spec.replicas", w.Replicas)
+			}
+			podSpec, _ := nested(doc, "spec", "template", "spec")
+			containers, _ := podSpec["containers"].([]interface{})
+			for _, c := range containers {
+				if container, ok := c.(map[string]interface{}); ok {
+					setValue(container, "resources", resources)
+				}
+			}
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// nested returns the map at the path below values.
+func nested(values map[string]interface{}, path ...string) (map[string]interface{}, bool) {
+	for _, key := range path {
+		next, ok := values[key].(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		values = next
+	}
+	return values, true
+}
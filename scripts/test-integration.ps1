@@ -65,7 +65,7 @@ if ($pods -notlike "*redis*") {
     Write-Host "Error: Redis pod not found"
     exit 1
 }
-$ingress = kubectl get ingress -n default
+$ingress = kubectl get ingress -n kubernetes-dashboard
 if ($ingress -notlike "*dashboard-ingress*") {
     Write-Host "Error: Dashboard ingress not found"
     exit 1
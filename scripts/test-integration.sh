@@ -65,7 +65,7 @@ if ! kubectl get pods -n default | grep -q "redis"; then
     echo "Error: Redis pod not found"
     exit 1
 fi
-if ! kubectl get ingress -n default | grep -q "dashboard-ingress"; then
+if ! kubectl get ingress -n kubernetes-dashboard | grep -q "dashboard-ingress"; then
     echo "Error: Dashboard ingress not found"
     exit 1
 fi